@@ -0,0 +1,185 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"math/big"
+	"strings"
+	"sync"
+)
+
+// chunkSize is roughly how many bytes of whole lines are handed to a worker at a time
+const chunkSize = 1 << 18
+
+// bigFuel is fuel under the model for masses too large for an int
+func (m fuelModel) bigFuel(mass *big.Int) *big.Int {
+	f := new(big.Int).Set(mass)
+	switch m.Rounding {
+	case "ceil":
+		f.Add(f, big.NewInt(int64(m.Divisor-1)))
+	case "nearest":
+		f.Add(f, big.NewInt(int64(m.Divisor/2)))
+	}
+	f.Quo(f, big.NewInt(int64(m.Divisor)))
+	f.Sub(f, big.NewInt(int64(m.Offset)))
+	return f
+}
+
+// bigSubFuel is subFuel for masses too large for an int
+func (m fuelModel) bigSubFuel(mass *big.Int) *big.Int {
+	f := m.bigFuel(mass)
+	if f.Cmp(big.NewInt(int64(m.Minimum))) < 0 {
+		f.SetInt64(0)
+	}
+	return f
+}
+
+// bigAllFuel is allFuel under the model for masses too large for an int
+func (m fuelModel) bigAllFuel(mass *big.Int) *big.Int {
+	if !m.Recursive {
+		return m.bigFuel(mass)
+	}
+	totalFuel := new(big.Int)
+	for subFuel := m.bigSubFuel(mass); subFuel.Sign() > 0; subFuel = m.bigSubFuel(subFuel) {
+		totalFuel.Add(totalFuel, subFuel)
+	}
+	return totalFuel
+}
+
+// parseBigMass is parseMass without the size limit
+func parseBigMass(text string) (*big.Int, string) {
+	mass, ok := new(big.Int).SetString(strings.TrimSpace(text), 10)
+	if !ok {
+		return nil, "bad mass"
+	}
+	if mass.Sign() < 0 {
+		return nil, "negative mass"
+	}
+	return mass, ""
+}
+
+// batch is a run of whole lines starting at line first
+type batch struct {
+	first int
+	data  []byte
+}
+
+// totals is the running sum of both parts
+type totals struct {
+	modules      int
+	part1, part2 *big.Int
+	err          *lineError
+}
+
+// add sums a batch into t.  Masses that fit in an int take the fast path,
+// with the int totals folded into the big ones before they could overflow.
+func (t *totals) add(b batch, v validation, model fuelModel, filename string) {
+	var small1, small2 int
+	for i, data := 0, b.data; len(data) > 0; i++ {
+		end := bytes.IndexByte(data, '\n')
+		if end < 0 {
+			end = len(data)
+		}
+		text := string(data[:end])
+		data = data[min(end+1, len(data)):]
+		if v.skip(text) {
+			continue
+		}
+		if mass, reason := parseMass(text); reason == "" && mass < 1<<52 {
+			small1 += model.fuel(mass)
+			small2 += model.allFuel(mass)
+			t.modules++
+			if small2 > 1<<60 {
+				t.part1.Add(t.part1, big.NewInt(int64(small1)))
+				t.part2.Add(t.part2, big.NewInt(int64(small2)))
+				small1, small2 = 0, 0
+			}
+			continue
+		}
+		mass, reason := parseBigMass(text)
+		if reason != "" {
+			err := &lineError{filename, b.first + i, text, reason}
+			if !v.lenient {
+				if t.err == nil || err.line < t.err.line {
+					t.err = err
+				}
+				return
+			}
+			log.Printf("skipping %v", err)
+			continue
+		}
+		t.part1.Add(t.part1, model.bigFuel(mass))
+		t.part2.Add(t.part2, model.bigAllFuel(mass))
+		t.modules++
+	}
+	t.part1.Add(t.part1, big.NewInt(int64(small1)))
+	t.part2.Add(t.part2, big.NewInt(int64(small2)))
+}
+
+// streamFuel sums both parts over every mass in r without holding the file in
+// memory, spreading chunks of lines over the given number of workers.  Lines
+// have no length limit.
+func streamFuel(r io.Reader, filename string, v validation, model fuelModel, workers int) (totals, error) {
+	batches := make(chan batch, workers)
+	results := make(chan totals, workers)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			t := totals{part1: new(big.Int), part2: new(big.Int)}
+			for b := range batches {
+				t.add(b, v, model, filename)
+			}
+			results <- t
+		}()
+	}
+
+	// read chunks, holding back any partial line at the end for the next one
+	var readErr error
+	line := 1
+	var partial []byte
+	for {
+		chunk := make([]byte, len(partial), len(partial)+chunkSize)
+		copy(chunk, partial)
+		n, err := io.ReadFull(r, chunk[len(chunk):cap(chunk)])
+		chunk = chunk[:len(chunk)+n]
+		end := bytes.LastIndexByte(chunk, '\n') + 1
+		if err != nil {
+			end = len(chunk)
+		}
+		partial = chunk[end:]
+		if end > 0 {
+			batches <- batch{line, chunk[:end]}
+			line += bytes.Count(chunk[:end], []byte{'\n'})
+		}
+		if err != nil {
+			if err != io.EOF && err != io.ErrUnexpectedEOF {
+				readErr = fmt.Errorf("reading %s: %w", filename, err)
+			}
+			break
+		}
+	}
+	close(batches)
+	wg.Wait()
+	close(results)
+
+	sum := totals{part1: new(big.Int), part2: new(big.Int)}
+	for t := range results {
+		sum.modules += t.modules
+		sum.part1.Add(sum.part1, t.part1)
+		sum.part2.Add(sum.part2, t.part2)
+		if t.err != nil && (sum.err == nil || t.err.line < sum.err.line) {
+			sum.err = t.err
+		}
+	}
+	if readErr != nil {
+		return sum, readErr
+	}
+	if sum.err != nil {
+		return sum, sum.err
+	}
+	return sum, nil
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"math/big"
+	"math/rand"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// manifest generates n random masses in the range of the puzzle input
+func manifest(n int) ([]int, string) {
+	rng := rand.New(rand.NewSource(1))
+	masses := make([]int, n)
+	var b strings.Builder
+	for i := range masses {
+		masses[i] = 50000 + rng.Intn(100000)
+		b.WriteString(strconv.Itoa(masses[i]))
+		b.WriteByte('\n')
+	}
+	return masses, b.String()
+}
+
+func TestStreamFuel(t *testing.T) {
+	masses, text := manifest(100017)
+	for _, workers := range []int{1, 2, 8} {
+		sum, err := streamFuel(strings.NewReader(text), "test", validation{}, defaultModel, workers)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if sum.modules != len(masses) || sum.part1.Int64() != int64(sumFuel(masses, fuel)) ||
+			sum.part2.Int64() != int64(sumFuel(masses, allFuel)) {
+			t.Errorf("%d workers: %d modules %v %v", workers, sum.modules, sum.part1, sum.part2)
+		}
+	}
+}
+
+// masses past the int fast path must match the int functions where both work
+func TestBigFuel(t *testing.T) {
+	for _, mass := range []int{12, 14, 1969, 100756, 1 << 40, 1<<62 + 12345} {
+		m := big.NewInt(int64(mass))
+		if f := defaultModel.bigFuel(m); f.Int64() != int64(fuel(mass)) {
+			t.Errorf("bigFuel(%d) = %v, want %d", mass, f, fuel(mass))
+		}
+		if f := defaultModel.bigAllFuel(m); f.Int64() != int64(allFuel(mass)) {
+			t.Errorf("bigAllFuel(%d) = %v, want %d", mass, f, allFuel(mass))
+		}
+	}
+}
+
+func BenchmarkSumFuel(b *testing.B) {
+	masses, _ := manifest(1 << 20)
+	for i := 0; i < b.N; i++ {
+		sumFuel(masses, allFuel)
+	}
+}
+
+func BenchmarkStreamFuel(b *testing.B) {
+	_, text := manifest(1 << 20)
+	for _, workers := range []int{1, 4} {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			b.SetBytes(int64(len(text)))
+			for i := 0; i < b.N; i++ {
+				if _, err := streamFuel(strings.NewReader(text), "bench", validation{}, defaultModel, workers); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
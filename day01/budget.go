@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+)
+
+// maxMass finds the largest mass whose fuel fits within budget.  fuelCalc
+// must never decrease as mass grows, which holds for allFuel and every
+// fuelModel, so the answer can be bracketed by doubling and then found by
+// binary search.
+func maxMass(budget int, fuelCalc func(int) int) int {
+	if fuelCalc(0) > budget {
+		return -1
+	}
+	lo, hi := 0, 1
+	for fuelCalc(hi) <= budget {
+		if hi == math.MaxInt {
+			return hi
+		}
+		lo = hi
+		if hi > math.MaxInt/2 {
+			hi = math.MaxInt
+		} else {
+			hi *= 2
+		}
+	}
+	// fuelCalc(lo) fits and fuelCalc(hi) doesn't
+	for hi-lo > 1 {
+		mid := lo + (hi-lo)/2
+		if fuelCalc(mid) <= budget {
+			lo = mid
+		} else {
+			hi = mid
+		}
+	}
+	return lo
+}
+
+// writeBudget reports how the modules fit in the budget and the largest module
+// that could be added in what is left over
+func writeBudget(w io.Writer, budget int, masses []int, fuelCalc func(int) int) error {
+	used := sumFuel(masses, fuelCalc)
+	if used > budget {
+		_, err := fmt.Fprintf(w, "Over budget: %d modules need %d fuel, %d more than %d\n",
+			len(masses), used, used-budget, budget)
+		return err
+	}
+	fmt.Fprintf(w, "Modules: %d need %d fuel, slack %d\n", len(masses), used, budget-used)
+	mass := maxMass(budget-used, fuelCalc)
+	_, err := fmt.Fprintf(w, "Largest extra module: mass %d needs %d fuel, slack %d\n",
+		mass, fuelCalc(mass), budget-used-fuelCalc(mass))
+	return err
+}
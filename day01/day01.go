@@ -1,10 +1,11 @@
 package main
 
 import (
-	"bufio"
+	"flag"
 	"fmt"
+	"log"
 	"os"
-	"strconv"
+	"runtime"
 )
 
 const exitError = 1
@@ -25,34 +26,133 @@ func allFuel(mass int) int {
 	return totalFuel
 }
 
-// fileFuel open a file and sum all fuel calculations
-func fileFuel(filename string, fuelCalc func(int) int) int {
-	file, err := os.Open(filename)
-	if err != nil {
-		os.Exit(exitError)
-	}
-	defer file.Close()
-
+// sumFuel adds up the fuel for every mass
+func sumFuel(masses []int, fuelCalc func(int) int) int {
 	totalFuel := 0
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		mass, _ := strconv.Atoi(scanner.Text())
+	for _, mass := range masses {
 		totalFuel += fuelCalc(mass)
 	}
-
-	if err := scanner.Err(); err != nil {
-		os.Exit(exitError)
-	}
-
 	return totalFuel
 }
 
 func main() {
 
-	if len(os.Args) < 2 {
+	format := flag.String("report", "", "list every module's fuel as table, csv or json instead of the totals")
+	var v validation
+	flag.BoolVar(&v.comments, "comments", false, "skip blank lines and lines starting with #")
+	flag.BoolVar(&v.lenient, "lenient", false, "warn about and skip bad lines instead of failing")
+	budget := flag.Int("budget", -1, "report the largest module mass whose part 2 fuel fits in this budget, after the input's modules if given")
+	stageSizes := flag.String("stages", "", "group the modules into stages of these sizes, top first, e.g. 40,30,30")
+	bigMode := flag.Bool("big", false, "stream the totals with arbitrary precision, for masses or files too large for the other modes")
+	workers := flag.Int("workers", runtime.NumCPU(), "goroutines summing lines with -big")
+	modelFile := flag.String("model", "", "read the fuel model from this JSON file, other model flags override it")
+	model := defaultModel
+	flag.IntVar(&model.Divisor, "divisor", model.Divisor, "divide the mass by this")
+	flag.IntVar(&model.Offset, "offset", model.Offset, "then subtract this")
+	flag.StringVar(&model.Rounding, "rounding", model.Rounding, "round the division: floor, ceil or nearest")
+	flag.IntVar(&model.Minimum, "min", model.Minimum, "stop adding fuel for fuel once it drops below this")
+	flag.BoolVar(&model.Recursive, "recursive", model.Recursive, "add fuel for the fuel in part 2")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: day01 [flags] input.txt (- for stdin, may be gzip'd)\n       day01 [model flags] manifest.csv|manifest.json\n       day01 -budget fuel [flags] [input.txt]\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() < 1 && *budget < 0 {
+		flag.Usage()
 		os.Exit(exitError)
 	}
+	filename := flag.Arg(0)
+
+	if *workers < 1 {
+		log.Fatalf("Need at least one worker, got %d", *workers)
+	}
+
+	if *modelFile != "" {
+		loaded, err := loadModel(*modelFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		// flags given on the command line win over the file
+		flag.Visit(func(f *flag.Flag) {
+			switch f.Name {
+			case "divisor":
+				loaded.Divisor = model.Divisor
+			case "offset":
+				loaded.Offset = model.Offset
+			case "rounding":
+				loaded.Rounding = model.Rounding
+			case "min":
+				loaded.Minimum = model.Minimum
+			case "recursive":
+				loaded.Recursive = model.Recursive
+			}
+		})
+		model = loaded
+	}
+	if err := model.check(); err != nil {
+		log.Fatalf("Bad fuel model: %v", err)
+	}
+
+	if manifestFormat(filename) != "" {
+		entries, err := loadManifest(filename)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := writeGroups(os.Stdout, groupTotals(entries, model)); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	if *bigMode && filename != "" {
+		file, err := openMasses(filename)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer file.Close()
+		sum, err := streamFuel(file, filename, v, model, *workers)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("Part 1: %v\n", sum.part1)
+		fmt.Printf("Part 2: %v\n", sum.part2)
+		return
+	}
+
+	var masses []int
+	if filename != "" {
+		loaded, err := v.loadMasses(filename)
+		if err != nil {
+			log.Fatal(err)
+		}
+		masses = loaded
+	}
+	if *budget >= 0 {
+		if err := writeBudget(os.Stdout, *budget, masses, model.allFuel); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	if *stageSizes != "" {
+		sizes, err := parseStages(*stageSizes)
+		if err != nil {
+			log.Fatal(err)
+		}
+		stages, err := stack(masses, sizes, model)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := writeStages(os.Stdout, stages); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	if *format != "" {
+		if err := newReport(masses, model).write(os.Stdout, *format); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
 
-	fmt.Printf("Part 1: %d\n", fileFuel(os.Args[1], fuel))
-	fmt.Printf("Part 2: %d\n", fileFuel(os.Args[1], allFuel))
+	fmt.Printf("Part 1: %d\n", sumFuel(masses, model.fuel))
+	fmt.Printf("Part 2: %d\n", sumFuel(masses, model.cachedAllFuel()))
 }
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// entry is one kind of module in a manifest, needed quantity times
+type entry struct {
+	ID       string `json:"id"`
+	Mass     int    `json:"mass"`
+	Quantity *int   `json:"quantity"` // defaults to 1
+	Group    string `json:"group"`
+}
+
+func (e entry) count() int {
+	if e.Quantity == nil {
+		return 1
+	}
+	return *e.Quantity
+}
+
+// check rejects entries that can't be fuelled
+func (e entry) check() error {
+	if e.Mass < 0 {
+		return fmt.Errorf("negative mass %d", e.Mass)
+	}
+	if e.count() < 0 {
+		return fmt.Errorf("negative quantity %d", e.count())
+	}
+	return nil
+}
+
+// manifestFormat returns csv or json for manifest files, or "" for plain mass lists
+func manifestFormat(filename string) string {
+	ext := filepath.Ext(strings.TrimSuffix(filename, ".gz"))
+	switch ext {
+	case ".csv", ".json":
+		return ext[1:]
+	}
+	return ""
+}
+
+// readCSV reads a manifest with a header row naming its columns: mass is
+// required, id, quantity and group are optional
+func readCSV(r io.Reader, filename string) ([]entry, error) {
+	cr := csv.NewReader(r)
+	cr.Comment = '#'
+	cr.TrimLeadingSpace = true
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("%s: reading header: %w", filename, err)
+	}
+	columns := map[string]int{"id": -1, "mass": -1, "quantity": -1, "group": -1}
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("%s: unknown column %q", filename, name)
+		}
+		columns[name] = i
+	}
+	if columns["mass"] < 0 {
+		return nil, fmt.Errorf("%s: no mass column", filename)
+	}
+
+	var entries []entry
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", filename, err)
+		}
+		line, _ := cr.FieldPos(0)
+		field := func(name string) string {
+			if i := columns[name]; i >= 0 {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+		e := entry{ID: field("id"), Group: field("group")}
+		if e.Mass, err = strconv.Atoi(field("mass")); err != nil {
+			return nil, &lineError{filename, line, field("mass"), "bad mass"}
+		}
+		if text := field("quantity"); text != "" {
+			quantity, err := strconv.Atoi(text)
+			if err != nil {
+				return nil, &lineError{filename, line, text, "bad quantity"}
+			}
+			e.Quantity = &quantity
+		}
+		if err := e.check(); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", filename, line, err)
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+// readJSON reads a manifest holding an array of entries
+func readJSON(r io.Reader, filename string) ([]entry, error) {
+	var entries []entry
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&entries); err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+	for i, e := range entries {
+		if err := e.check(); err != nil {
+			return nil, fmt.Errorf("%s: module %d (id %q): %w", filename, i+1, e.ID, err)
+		}
+	}
+	return entries, nil
+}
+
+// loadManifest reads a CSV or JSON manifest
+func loadManifest(filename string) ([]entry, error) {
+	file, err := openMasses(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	switch manifestFormat(filename) {
+	case "csv":
+		return readCSV(file, filename)
+	case "json":
+		return readJSON(file, filename)
+	}
+	return nil, errors.New("not a manifest: " + filename)
+}
+
+// groupTotal sums the entries in one group
+type groupTotal struct {
+	Group   string
+	Modules int
+	Mass    int
+	Fuel    int
+	AllFuel int
+}
+
+// groupTotals sums fuel for each group, in name order
+func groupTotals(entries []entry, model fuelModel) []groupTotal {
+	allFuel := model.cachedAllFuel()
+	index := make(map[string]int)
+	var groups []groupTotal
+	for _, e := range entries {
+		i, ok := index[e.Group]
+		if !ok {
+			i = len(groups)
+			index[e.Group] = i
+			groups = append(groups, groupTotal{Group: e.Group})
+		}
+		g := &groups[i]
+		n := e.count()
+		g.Modules += n
+		g.Mass += n * e.Mass
+		g.Fuel += n * model.fuel(e.Mass)
+		g.AllFuel += n * allFuel(e.Mass)
+	}
+	sort.Slice(groups, func(i, j int) bool { return groups[i].Group < groups[j].Group })
+	return groups
+}
+
+// writeGroups prints fuel per group and overall
+func writeGroups(w io.Writer, groups []groupTotal) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintf(tw, "group\tmodules\tmass\tfuel\tallFuel\t\n")
+	var total groupTotal
+	for _, g := range groups {
+		name := g.Group
+		if name == "" {
+			name = "-"
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%d\t\n", name, g.Modules, g.Mass, g.Fuel, g.AllFuel)
+		total.Modules += g.Modules
+		total.Mass += g.Mass
+		total.Fuel += g.Fuel
+		total.AllFuel += g.AllFuel
+	}
+	fmt.Fprintf(tw, "total\t%d\t%d\t%d\t%d\t\n", total.Modules, total.Mass, total.Fuel, total.AllFuel)
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintf(w, "Part 1: %d\nPart 2: %d\n", total.Fuel, total.AllFuel)
+	return err
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// lineError locates a bad line in a mass file
+type lineError struct {
+	filename string
+	line     int
+	text     string
+	reason   string
+}
+
+func (e *lineError) Error() string {
+	return fmt.Sprintf("%s:%d: %s %q", e.filename, e.line, e.reason, e.text)
+}
+
+// validation says which lines of a mass file are acceptable
+type validation struct {
+	comments bool // skip blank lines and lines starting with #
+	lenient  bool // warn about and skip bad lines instead of failing
+}
+
+// skip reports whether a line is a comment or blank line to be skipped
+func (v validation) skip(text string) bool {
+	trimmed := strings.TrimSpace(text)
+	return v.comments && (trimmed == "" || strings.HasPrefix(trimmed, "#"))
+}
+
+// parseMass checks a single line holds a non-negative mass
+func parseMass(text string) (int, string) {
+	mass, err := strconv.Atoi(strings.TrimSpace(text))
+	if err != nil {
+		return 0, "bad mass"
+	}
+	if mass < 0 {
+		return 0, "negative mass"
+	}
+	return mass, ""
+}
+
+// readMasses reads one mass per line from r, naming filename in errors
+func (v validation) readMasses(r io.Reader, filename string) ([]int, error) {
+	masses := make([]int, 0, 1<<8)
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := scanner.Text()
+		if v.skip(text) {
+			continue
+		}
+		mass, reason := parseMass(text)
+		if reason != "" {
+			err := &lineError{filename, line, text, reason}
+			if !v.lenient {
+				return nil, err
+			}
+			log.Printf("skipping %v", err)
+			continue
+		}
+		masses = append(masses, mass)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading %s: %w", filename, err)
+	}
+
+	return masses, nil
+}
+
+// openMasses opens a mass file, or stdin for "-", decompressing gzip'd input
+func openMasses(filename string) (io.ReadCloser, error) {
+	var file io.ReadCloser = os.Stdin
+	if filename != "-" {
+		f, err := os.Open(filename)
+		if err != nil {
+			return nil, err
+		}
+		file = f
+	}
+	buffered := bufio.NewReaderSize(file, 1<<16)
+	if magic, _ := buffered.Peek(2); len(magic) == 2 && magic[0] == 0x1f && magic[1] == 0x8b {
+		z, err := gzip.NewReader(buffered)
+		if err != nil {
+			file.Close()
+			return nil, fmt.Errorf("%s: %w", filename, err)
+		}
+		return readCloser{z, file}, nil
+	}
+	return readCloser{buffered, file}, nil
+}
+
+// readCloser reads through a wrapper but closes the underlying file
+type readCloser struct {
+	io.Reader
+	file io.Closer
+}
+
+func (r readCloser) Close() error {
+	return r.file.Close()
+}
+
+// loadMasses reads the masses in a file
+func (v validation) loadMasses(filename string) ([]int, error) {
+	file, err := openMasses(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return v.readMasses(file, filename)
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// fuelModel generalises fuel to round(mass/divisor) - offset.  Recursive
+// models also fuel their fuel like allFuel, until it drops below minimum.
+type fuelModel struct {
+	Divisor   int    `json:"divisor"`
+	Offset    int    `json:"offset"`
+	Rounding  string `json:"rounding"` // floor, ceil or nearest
+	Minimum   int    `json:"minimum"`
+	Recursive bool   `json:"recursive"`
+}
+
+// defaultModel is the puzzle's mass/3 - 2
+var defaultModel = fuelModel{Divisor: 3, Offset: 2, Rounding: "floor", Minimum: 1, Recursive: true}
+
+// loadModel reads a JSON model, fields left out keep their defaults
+func loadModel(filename string) (fuelModel, error) {
+	m := defaultModel
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return m, err
+	}
+	if err := json.Unmarshal(data, &m); err != nil {
+		return m, fmt.Errorf("%s: %w", filename, err)
+	}
+	return m, nil
+}
+
+// check rejects models that can't be evaluated, including recursive ones
+// whose fuel would never run out
+func (m fuelModel) check() error {
+	if m.Divisor < 1 {
+		return fmt.Errorf("divisor %d must be positive", m.Divisor)
+	}
+	switch m.Rounding {
+	case "floor", "ceil", "nearest":
+	default:
+		return fmt.Errorf("unknown rounding %q", m.Rounding)
+	}
+	if m.Recursive && m.Minimum < 1 {
+		return errors.New("recursive fuel needs a minimum of at least 1")
+	}
+	// fuel(f) < f for every f >= 1 needs the quotient to shrink and the
+	// offset not to add it back, floor only shrinks without help
+	if m.Recursive && (m.Divisor < 2 || m.Offset < 0 || (m.Rounding != "floor" && m.Offset < 1)) {
+		return errors.New("recursive fuel would never run out, use a divisor of at least 2 and a positive offset")
+	}
+	return nil
+}
+
+// fuel is the model's version of fuel, the default model agrees with fuel exactly
+func (m fuelModel) fuel(mass int) int {
+	var quotient int
+	switch m.Rounding {
+	case "floor":
+		quotient = mass / m.Divisor
+	case "ceil":
+		quotient = (mass + m.Divisor - 1) / m.Divisor
+	case "nearest":
+		quotient = (mass + m.Divisor/2) / m.Divisor
+	}
+	return quotient - m.Offset
+}
+
+// subFuel is fuel counting anything below the minimum as none, the step
+// allFuel repeats
+func (m fuelModel) subFuel(mass int) int {
+	if f := m.fuel(mass); f >= m.Minimum {
+		return f
+	}
+	return 0
+}
+
+// allFuel is the model's version of allFuel
+func (m fuelModel) allFuel(mass int) int {
+	if !m.Recursive {
+		return m.fuel(mass)
+	}
+	var totalFuel int
+	for subFuel := m.subFuel(mass); subFuel > 0; subFuel = m.subFuel(subFuel) {
+		totalFuel += subFuel
+	}
+	return totalFuel
+}
+
+// stages counts the positive fuel amounts allFuel adds up for mass
+func (m fuelModel) stages(mass int) int {
+	var n int
+	for subFuel := m.subFuel(mass); subFuel > 0; subFuel = m.subFuel(subFuel) {
+		n++
+		if !m.Recursive {
+			break
+		}
+	}
+	return n
+}
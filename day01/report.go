@@ -0,0 +1,123 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"text/tabwriter"
+)
+
+// module is one row of the fuel report
+type module struct {
+	Index   int     `json:"module"` // counting from 1 in file order
+	Mass    int     `json:"mass"`
+	Fuel    int     `json:"fuel"`
+	AllFuel int     `json:"allFuel"`
+	Stages  int     `json:"stages"`
+	Share   float64 `json:"share"` // percent of the total allFuel
+}
+
+// summary totals a report
+type summary struct {
+	Modules  int     `json:"modules"`
+	Mass     int     `json:"mass"`
+	Fuel     int     `json:"fuel"`
+	AllFuel  int     `json:"allFuel"`
+	MinFuel  int     `json:"minAllFuel"`
+	MaxFuel  int     `json:"maxAllFuel"`
+	MeanFuel float64 `json:"meanAllFuel"`
+	Stages   int     `json:"maxStages"`
+	Largest  int     `json:"largestModule"` // the module needing the most allFuel
+}
+
+type report struct {
+	Modules []module `json:"modules"`
+	Summary summary  `json:"summary"`
+}
+
+// newReport works out every module's fuel under the model and the totals
+func newReport(masses []int, model fuelModel) report {
+	r := report{Modules: make([]module, len(masses))}
+	s := &r.Summary
+	allFuel := model.cachedAllFuel()
+	for i, mass := range masses {
+		m := module{Index: i + 1, Mass: mass, Fuel: model.fuel(mass), AllFuel: allFuel(mass), Stages: model.stages(mass)}
+		r.Modules[i] = m
+		s.Modules++
+		s.Mass += m.Mass
+		s.Fuel += m.Fuel
+		s.AllFuel += m.AllFuel
+		if i == 0 || m.AllFuel < s.MinFuel {
+			s.MinFuel = m.AllFuel
+		}
+		if i == 0 || m.AllFuel > s.MaxFuel {
+			s.MaxFuel = m.AllFuel
+			s.Largest = m.Index
+		}
+		if m.Stages > s.Stages {
+			s.Stages = m.Stages
+		}
+	}
+	if s.Modules > 0 {
+		s.MeanFuel = float64(s.AllFuel) / float64(s.Modules)
+	}
+	for i := range r.Modules {
+		if s.AllFuel != 0 {
+			r.Modules[i].Share = 100 * float64(r.Modules[i].AllFuel) / float64(s.AllFuel)
+		}
+	}
+	return r
+}
+
+// writeTable prints the report as aligned columns
+func (r report) writeTable(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintf(tw, "module\tmass\tfuel\tallFuel\tstages\tshare\t\n")
+	for _, m := range r.Modules {
+		fmt.Fprintf(tw, "%d\t%d\t%d\t%d\t%d\t%.2f%%\t\n", m.Index, m.Mass, m.Fuel, m.AllFuel, m.Stages, m.Share)
+	}
+	s := r.Summary
+	fmt.Fprintf(tw, "total\t%d\t%d\t%d\t%d\t\t\n", s.Mass, s.Fuel, s.AllFuel, s.Stages)
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintf(w, "%d modules, allFuel min %d max %d (module %d) mean %.2f\n",
+		s.Modules, s.MinFuel, s.MaxFuel, s.Largest, s.MeanFuel)
+	return err
+}
+
+// writeCSV prints one record per module followed by a total record
+func (r report) writeCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"module", "mass", "fuel", "allFuel", "stages", "share"})
+	for _, m := range r.Modules {
+		cw.Write([]string{strconv.Itoa(m.Index), strconv.Itoa(m.Mass), strconv.Itoa(m.Fuel),
+			strconv.Itoa(m.AllFuel), strconv.Itoa(m.Stages), strconv.FormatFloat(m.Share, 'f', 4, 64)})
+	}
+	s := r.Summary
+	cw.Write([]string{"total", strconv.Itoa(s.Mass), strconv.Itoa(s.Fuel),
+		strconv.Itoa(s.AllFuel), strconv.Itoa(s.Stages), "100"})
+	cw.Flush()
+	return cw.Error()
+}
+
+func (r report) writeJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+// write prints the report in the named format
+func (r report) write(w io.Writer, format string) error {
+	switch format {
+	case "table":
+		return r.writeTable(w)
+	case "csv":
+		return r.writeCSV(w)
+	case "json":
+		return r.writeJSON(w)
+	}
+	return fmt.Errorf("unknown report format %q", format)
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// stage is one stage of a rocket.  Besides its own modules it has to lift the
+// payload: the mass and fuel of every stage above it.
+type stage struct {
+	Modules     int
+	Mass        int
+	ModuleFuel  int // allFuel for each module on its own
+	Payload     int
+	PayloadFuel int // allFuel for the payload as a single mass
+}
+
+func (s stage) fuel() int {
+	return s.ModuleFuel + s.PayloadFuel
+}
+
+// parseStages reads a comma separated list of module counts per stage
+func parseStages(text string) ([]int, error) {
+	var sizes []int
+	for _, field := range strings.Split(text, ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(field))
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("bad stage size %q", field)
+		}
+		sizes = append(sizes, n)
+	}
+	return sizes, nil
+}
+
+// stack splits the masses into stages of the given sizes, top stage first,
+// and works out each stage's fuel from the top down
+func stack(masses []int, sizes []int, model fuelModel) ([]stage, error) {
+	total := 0
+	for _, n := range sizes {
+		total += n
+	}
+	if total != len(masses) {
+		return nil, fmt.Errorf("stages hold %d modules but there are %d", total, len(masses))
+	}
+
+	stages := make([]stage, len(sizes))
+	payload := 0
+	for i, n := range sizes {
+		s := stage{Modules: n, Payload: payload, PayloadFuel: model.allFuel(payload)}
+		for _, mass := range masses[:n] {
+			s.Mass += mass
+			s.ModuleFuel += model.allFuel(mass)
+		}
+		masses = masses[n:]
+		stages[i] = s
+		payload += s.Mass + s.fuel()
+	}
+	return stages, nil
+}
+
+// writeStages prints per-stage totals and the total for the full stack
+func writeStages(w io.Writer, stages []stage) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintf(tw, "stage\tmodules\tmass\tmodule fuel\tpayload\tpayload fuel\tstage fuel\t\n")
+	var mass, totalFuel int
+	for i, s := range stages {
+		fmt.Fprintf(tw, "%d\t%d\t%d\t%d\t%d\t%d\t%d\t\n",
+			i+1, s.Modules, s.Mass, s.ModuleFuel, s.Payload, s.PayloadFuel, s.fuel())
+		mass += s.Mass
+		totalFuel += s.fuel()
+	}
+	fmt.Fprintf(tw, "total\t\t%d\t\t\t\t%d\t\n", mass, totalFuel)
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintf(w, "Launch mass: %d\n", mass+totalFuel)
+	return err
+}
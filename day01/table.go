@@ -0,0 +1,64 @@
+package main
+
+import "fmt"
+
+// tableSize is how many small masses a fuelTable precomputes
+const tableSize = 1 << 16
+
+// fuelTable computes allFuel for any fuel function whose fuel is always less
+// than the mass, looking small masses up in a table built bottom up and
+// remembering larger ones, so repeated masses cost a map lookup.  The table
+// checks the masses it holds; past it, fuel that doesn't shrink never returns,
+// so callers need a guarantee such as fuelModel.check.  It is not safe for
+// concurrent use.
+type fuelTable struct {
+	fuel  func(int) int
+	small []int
+	memo  map[int]int
+}
+
+// newFuelTable builds the table, failing if fuelCalc ever gives a small mass
+// at least its own weight in fuel
+func newFuelTable(size int, fuelCalc func(int) int) (*fuelTable, error) {
+	t := &fuelTable{fuel: fuelCalc, small: make([]int, size), memo: make(map[int]int)}
+	for mass := range t.small {
+		f := fuelCalc(mass)
+		if f >= mass && f > 0 {
+			return nil, fmt.Errorf("fuel %d for mass %d doesn't shrink", f, mass)
+		}
+		// fuel for fuel is already in the table as it is lighter than mass
+		if f > 0 {
+			t.small[mass] = f + t.small[f]
+		}
+	}
+	return t, nil
+}
+
+// allFuel agrees with allFuel for the table's fuel function
+func (t *fuelTable) allFuel(mass int) int {
+	if mass >= 0 && mass < len(t.small) {
+		return t.small[mass]
+	}
+	if total, ok := t.memo[mass]; ok {
+		return total
+	}
+	var total int
+	if f := t.fuel(mass); f > 0 {
+		total = f + t.allFuel(f)
+	}
+	t.memo[mass] = total
+	return total
+}
+
+// cachedAllFuel is the model's allFuel, through a fuelTable for recursive
+// models, which check has already made sure shrink
+func (m fuelModel) cachedAllFuel() func(int) int {
+	if !m.Recursive {
+		return m.allFuel
+	}
+	t, err := newFuelTable(tableSize, m.subFuel)
+	if err != nil {
+		return m.allFuel
+	}
+	return t.allFuel
+}
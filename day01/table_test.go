@@ -0,0 +1,104 @@
+package main
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// the table must agree with allFuel for every mass up to a large bound
+func TestFuelTableAgrees(t *testing.T) {
+	bound := 1 << 22
+	if testing.Short() {
+		bound = 1 << 18
+	}
+	table := mustTable(t, tableSize, fuel)
+	for mass := 0; mass <= bound; mass++ {
+		if got, want := table.allFuel(mass), allFuel(mass); got != want {
+			t.Fatalf("allFuel(%d) = %d, want %d", mass, got, want)
+		}
+	}
+}
+
+// and for random masses far past the table, asked for more than once
+func TestFuelTableLargeMasses(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	table := mustTable(t, tableSize, fuel)
+	for i := 0; i < 100000; i++ {
+		mass := rng.Int63n(1 << 50)
+		if i%2 == 1 {
+			mass = int64(i % 1000 * 1000003)
+		}
+		if got, want := table.allFuel(int(mass)), allFuel(int(mass)); got != want {
+			t.Fatalf("allFuel(%d) = %d, want %d", mass, got, want)
+		}
+	}
+}
+
+// other recursive models must agree with their own allFuel
+func TestFuelTableModels(t *testing.T) {
+	models := []fuelModel{
+		{Divisor: 2, Offset: 0, Rounding: "floor", Minimum: 1, Recursive: true},
+		{Divisor: 4, Offset: 1, Rounding: "ceil", Minimum: 3, Recursive: true},
+		{Divisor: 3, Offset: 1, Rounding: "nearest", Minimum: 1, Recursive: true},
+	}
+	for _, model := range models {
+		table := mustTable(t, 1<<10, model.subFuel)
+		for mass := 0; mass <= 1<<16; mass++ {
+			if got, want := table.allFuel(mass), model.allFuel(mass); got != want {
+				t.Fatalf("%+v: allFuel(%d) = %d, want %d", model, mass, got, want)
+			}
+		}
+	}
+}
+
+func mustTable(tb testing.TB, size int, fuelCalc func(int) int) *fuelTable {
+	table, err := newFuelTable(size, fuelCalc)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return table
+}
+
+// fuel functions with a fixed point can't be tabled
+func TestFuelTableRejectsGrowth(t *testing.T) {
+	grows := fuelModel{Divisor: 2, Offset: -5, Rounding: "floor", Minimum: 1}
+	if _, err := newFuelTable(1<<10, grows.fuel); err == nil {
+		t.Error("table built for fuel that doesn't shrink")
+	}
+}
+
+// masses drawn from a small set, like a manifest of repeated module types
+func repeatedMasses() []int {
+	rng := rand.New(rand.NewSource(1))
+	kinds := make([]int, 64)
+	for i := range kinds {
+		kinds[i] = 50000 + rng.Intn(1<<30)
+	}
+	masses := make([]int, 1<<16)
+	for i := range masses {
+		masses[i] = kinds[rng.Intn(len(kinds))]
+	}
+	return masses
+}
+
+func BenchmarkAllFuel(b *testing.B) {
+	masses := repeatedMasses()
+	for i := 0; i < b.N; i++ {
+		sumFuel(masses, allFuel)
+	}
+}
+
+func BenchmarkFuelTable(b *testing.B) {
+	masses := repeatedMasses()
+	table := mustTable(b, tableSize, fuel)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		sumFuel(masses, table.allFuel)
+	}
+}
+
+func BenchmarkFuelTableBuild(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		mustTable(b, tableSize, fuel)
+	}
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+)
+
+// lookupMaps indexes points by their X and Y coordinates for intersectDistances
+func lookupMaps(points []point) (xMap map[int][]point, yMap map[int][]point) {
+	xMap = make(map[int][]point)
+	yMap = make(map[int][]point)
+	for _, v := range points {
+		xMap[v.X] = append(xMap[v.X], v)
+		yMap[v.Y] = append(yMap[v.Y], v)
+	}
+	return xMap, yMap
+}
+
+// wireSteps finds, for every wire, the steps to each of the points it crosses
+func wireSteps(wires [][]segment, points []point, touching bool) []map[point]int {
+	xMap, yMap := lookupMaps(points)
+	steps := make([]map[point]int, len(wires))
+	for i, wire := range wires {
+		steps[i] = intersectDistances(wire, yMap, xMap, touching)
+	}
+	return steps
+}
+
+// pairSummary describes the intersections of wires i and j
+type pairSummary struct {
+	i, j      int
+	points    []point
+	crossings int
+	closest   int // manhattan distance of the closest intersection
+	steps     int // fewest combined steps to an intersection
+}
+
+// summarisePairs looks at every pair of wires in turn
+func summarisePairs(wires [][]segment, steps []map[point]int, touching bool) []pairSummary {
+	var pairs []pairSummary
+	for i := 0; i < len(wires); i++ {
+		for j := i + 1; j < len(wires); j++ {
+			pair := pairSummary{i: i, j: j}
+			for _, v := range pairIntersections(wires[i], wires[j], touching) {
+				distance := v.distance()
+				combined := steps[i][v] + steps[j][v]
+				if pair.crossings == 0 || distance < pair.closest {
+					pair.closest = distance
+				}
+				if pair.crossings == 0 || combined < pair.steps {
+					pair.steps = combined
+				}
+				pair.crossings++
+				pair.points = append(pair.points, v)
+			}
+			pairs = append(pairs, pair)
+		}
+	}
+	return pairs
+}
+
+// multiCrossing is a point crossed by several wires
+type multiCrossing struct {
+	p     point
+	wires []int
+	steps int // combined steps of all those wires
+}
+
+// multiCrossings finds the points crossed by at least k wires, fewest combined steps first
+func multiCrossings(wires [][]segment, steps []map[point]int, k int, touching bool) []multiCrossing {
+	crossed := make(map[point]map[int]bool)
+	for i := 0; i < len(wires); i++ {
+		for j := i + 1; j < len(wires); j++ {
+			for _, p := range pairIntersections(wires[i], wires[j], touching) {
+				if crossed[p] == nil {
+					crossed[p] = make(map[int]bool)
+				}
+				crossed[p][i] = true
+				crossed[p][j] = true
+			}
+		}
+	}
+
+	var crossings []multiCrossing
+	for p, byWire := range crossed {
+		if len(byWire) < k {
+			continue
+		}
+		c := multiCrossing{p: p}
+		for i := range wires {
+			if byWire[i] {
+				c.wires = append(c.wires, i)
+				c.steps += steps[i][p]
+			}
+		}
+		crossings = append(crossings, c)
+	}
+	sort.Slice(crossings, func(a, b int) bool {
+		ca, cb := crossings[a], crossings[b]
+		if ca.steps != cb.steps {
+			return ca.steps < cb.steps
+		}
+		if ca.p.distance() != cb.p.distance() {
+			return ca.p.distance() < cb.p.distance()
+		}
+		return ca.p.X < cb.p.X || (ca.p.X == cb.p.X && ca.p.Y < cb.p.Y)
+	})
+	return crossings
+}
+
+// writePairs prints every pair of wires, counting wires from 1, followed by
+// its intersections
+func writePairs(w io.Writer, pairs []pairSummary, steps []map[point]int) error {
+	for _, pair := range pairs {
+		if pair.crossings == 0 {
+			fmt.Fprintf(w, "wires %d,%d: no crossings\n", pair.i+1, pair.j+1)
+			continue
+		}
+		fmt.Fprintf(w, "wires %d,%d: %d crossings closest %d steps %d\n",
+			pair.i+1, pair.j+1, pair.crossings, pair.closest, pair.steps)
+		for _, p := range pair.points {
+			fmt.Fprintf(w, "  %d,%d: distance %d steps %d\n", p.X, p.Y, p.distance(), steps[pair.i][p]+steps[pair.j][p])
+		}
+	}
+	return nil
+}
+
+// writeMultiCrossings prints the points crossed by at least k wires
+func writeMultiCrossings(w io.Writer, crossings []multiCrossing, k int) error {
+	fmt.Fprintf(w, "%d points crossed by at least %d wires\n", len(crossings), k)
+	for _, c := range crossings {
+		wires := make([]int, len(c.wires))
+		for n, i := range c.wires {
+			wires[n] = i + 1
+		}
+		fmt.Fprintf(w, "%d,%d: wires %v distance %d steps %d\n", c.p.X, c.p.Y, wires, c.p.distance(), c.steps)
+	}
+	return nil
+}
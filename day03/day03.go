@@ -2,6 +2,7 @@ package main
 
 import (
 	"bufio"
+	"flag"
 	"fmt"
 	"log"
 	"os"
@@ -88,35 +89,87 @@ func (s segment) corrected() segment {
 	return s
 }
 
-// hIntersects assumes s is horizontal and s2 isn't
-func (s segment) hIntersects(s2 segment) bool {
+// hIntersects assumes s is horizontal and s2 isn't.  Unless touching is set
+// the segments must cross strictly inside both, so T-junctions and corners
+// where one segment ends on the other don't count.
+func (s segment) hIntersects(s2 segment, touching bool) bool {
 	c1 := s.corrected()
 	c2 := s2.corrected()
+	if touching {
+		return c1.Begin.X <= c2.Begin.X &&
+			c1.End.X >= c2.End.X &&
+			c2.Begin.Y <= c1.Begin.Y &&
+			c2.End.Y >= c1.End.Y
+	}
 	return c1.Begin.X < c2.Begin.X &&
 		c1.End.X > c2.End.X &&
 		c2.Begin.Y < c1.Begin.Y &&
 		c2.End.Y > c1.End.Y
 }
 
+// overlap assumes s and s2 are both horizontal or both vertical and returns
+// every lattice point they share, which is none unless they lie on the same line
+func (s segment) overlap(s2 segment) []point {
+	c1 := s.corrected()
+	c2 := s2.corrected()
+	var pts []point
+	if s.horizontal() {
+		if c1.Begin.Y != c2.Begin.Y {
+			return nil
+		}
+		for x := max(c1.Begin.X, c2.Begin.X); x <= min(c1.End.X, c2.End.X); x++ {
+			pts = append(pts, point{x, c1.Begin.Y})
+		}
+		return pts
+	}
+	if c1.Begin.X != c2.Begin.X {
+		return nil
+	}
+	for y := max(c1.Begin.Y, c2.Begin.Y); y <= min(c1.End.Y, c2.End.Y); y++ {
+		pts = append(pts, point{c1.Begin.X, y})
+	}
+	return pts
+}
+
+// gets the distinct intersections of two wires, other than the central port.
+// With touching set, points where one wire ends on the other and every point
+// where they run along the same line count too.
+func pairIntersections(iSegments []segment, jSegments []segment, touching bool) []point {
+	intersections := make([]point, 0, 1<<4)
+	seen := make(map[point]bool)
+	add := func(pt point) {
+		if pt != (point{}) && !seen[pt] {
+			seen[pt] = true
+			intersections = append(intersections, pt)
+		}
+	}
+	for _, iWire := range iSegments {
+		for _, jWire := range jSegments {
+			iHorizontal := iWire.horizontal()
+			jHorizontal := jWire.horizontal()
+			if iHorizontal != jHorizontal {
+				if iHorizontal && iWire.hIntersects(jWire, touching) {
+					add(point{jWire.Begin.X, iWire.Begin.Y})
+				} else if jHorizontal && jWire.hIntersects(iWire, touching) {
+					add(point{iWire.Begin.X, jWire.Begin.Y})
+				}
+			} else if touching {
+				for _, pt := range iWire.overlap(jWire) {
+					add(pt)
+				}
+			}
+		}
+	}
+	return intersections
+}
+
 // gets all the intersections of the multiple wires
-func wireIntersections(wires [][]segment) []point {
+func wireIntersections(wires [][]segment, touching bool) []point {
 
 	intersections := make([]point, 0, 1<<8)
 	for i := 0; i < len(wires); i++ {
 		for j := i + 1; j < len(wires); j++ {
-			for _, iWire := range wires[i] {
-				for _, jWire := range wires[j] {
-					iHorizontal := iWire.horizontal()
-					jHorizontal := jWire.horizontal()
-					if iHorizontal != jHorizontal {
-						if iHorizontal && iWire.hIntersects(jWire) {
-							intersections = append(intersections, point{jWire.Begin.X, iWire.Begin.Y})
-						} else if jHorizontal && jWire.hIntersects(iWire) {
-							intersections = append(intersections, point{iWire.Begin.X, jWire.Begin.Y})
-						}
-					}
-				}
-			}
+			intersections = append(intersections, pairIntersections(wires[i], wires[j], touching)...)
 		}
 	}
 	return intersections
@@ -143,7 +196,15 @@ func abs(x int) int {
 	return x
 }
 
-func intersectDistances(wires []segment, yMap map[int][]point, xMap map[int][]point) map[point]int {
+// steps along a wire to the first visit of each point in the lookup maps that
+// it crosses, including its corners and ends when touching is set
+func intersectDistances(wires []segment, yMap map[int][]point, xMap map[int][]point, touching bool) map[point]int {
+	inside := func(lo, v, hi int) bool {
+		if touching {
+			return lo <= v && v <= hi
+		}
+		return lo < v && v < hi
+	}
 	var traversal int
 	distances := make(map[point]int)
 	for _, wire := range wires {
@@ -152,7 +213,7 @@ func intersectDistances(wires []segment, yMap map[int][]point, xMap map[int][]po
 			pts, ok := yMap[wire.Begin.Y]
 			if ok {
 				for _, pt := range pts {
-					if c.Begin.X < pt.X && c.End.X > pt.X {
+					if _, seen := distances[pt]; !seen && inside(c.Begin.X, pt.X, c.End.X) {
 						distances[pt] = traversal + abs(pt.X-wire.Begin.X)
 					}
 				}
@@ -163,7 +224,7 @@ func intersectDistances(wires []segment, yMap map[int][]point, xMap map[int][]po
 			pts, ok := xMap[wire.Begin.X]
 			if ok {
 				for _, pt := range pts {
-					if c.Begin.Y < pt.Y && c.End.Y > pt.Y {
+					if _, seen := distances[pt]; !seen && inside(c.Begin.Y, pt.Y, c.End.Y) {
 						distances[pt] = traversal + abs(pt.Y-wire.Begin.Y)
 					}
 				}
@@ -176,38 +237,50 @@ func intersectDistances(wires []segment, yMap map[int][]point, xMap map[int][]po
 
 func main() {
 
-	if len(os.Args) < 2 {
+	showPairs := flag.Bool("pairs", false, "list the intersections of every pair of wires")
+	k := flag.Int("k", 0, "list the points crossed by at least this many wires")
+	touching := flag.Bool("touching", false, "count wires that meet end to side, at corners or along the same line as crossing")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: day03 [flags] input.txt\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() < 1 {
+		flag.Usage()
 		os.Exit(exitError)
 	}
 
-	wires := loadWires(os.Args[1])
-	intersections := wireIntersections(wires)
-	smallestDistance := intersections[0].distance()
-
-	xMap := make(map[int][]point)
-	yMap := make(map[int][]point)
-	for _, v := range intersections {
+	wires := loadWires(flag.Arg(0))
+	if len(wires) < 2 {
+		log.Fatalf("Need at least two wires, got %d", len(wires))
+	}
+	intersections := wireIntersections(wires, *touching)
+	if len(intersections) == 0 {
+		log.Fatalf("The wires never cross")
+	}
+	steps := wireSteps(wires, intersections, *touching)
+	pairs := summarisePairs(wires, steps, *touching)
 
-		// compute smallest distance
-		distance := v.distance()
-		if smallestDistance > distance {
-			smallestDistance = distance
+	// compute smallest distance and fewest steps across every pair
+	smallestDistance, shortestIntersection := -1, -1
+	for _, pair := range pairs {
+		if pair.crossings == 0 {
+			continue
+		}
+		if smallestDistance < 0 || pair.closest < smallestDistance {
+			smallestDistance = pair.closest
+		}
+		if shortestIntersection < 0 || pair.steps < shortestIntersection {
+			shortestIntersection = pair.steps
 		}
-
-		// fill lookup maps
-		xMap[v.X] = append(xMap[v.X], v)
-		yMap[v.Y] = append(yMap[v.Y], v)
 	}
 	fmt.Printf("Part 1: %d\n", smallestDistance)
+	fmt.Printf("Part 2: %d\n", shortestIntersection)
 
-	iDistances := intersectDistances(wires[0], yMap, xMap)
-	jDistances := intersectDistances(wires[1], yMap, xMap)
-	shortestIntersection := iDistances[intersections[0]] + jDistances[intersections[0]]
-	for _, v := range intersections {
-		interDist := iDistances[v] + jDistances[v]
-		if shortestIntersection > interDist {
-			shortestIntersection = interDist
-		}
+	if *showPairs {
+		writePairs(os.Stdout, pairs, steps)
+	}
+	if *k > 0 {
+		writeMultiCrossings(os.Stdout, multiCrossings(wires, steps, *k, *touching), *k)
 	}
-	fmt.Printf("Part 2: %d\n", shortestIntersection)
 }
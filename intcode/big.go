@@ -0,0 +1,241 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math/big"
+)
+
+// parseBigProgram reads a comma separated program of arbitrarily large values
+func parseBigProgram(r io.Reader) ([]*big.Int, error) {
+	tokens, err := readTokens(r)
+	if err != nil {
+		return nil, err
+	}
+	data := make([]*big.Int, len(tokens))
+	for i, token := range tokens {
+		val, ok := new(big.Int).SetString(token, 10)
+		if !ok {
+			return nil, fmt.Errorf("bad value %q at position %d", token, i)
+		}
+		data[i] = val
+	}
+	return data, nil
+}
+
+func loadBigProgram(filename string) (program []*big.Int, err error) {
+	err = openProgram(filename, func(r io.Reader) error {
+		program, err = parseBigProgram(r)
+		return err
+	})
+	return program, err
+}
+
+// bigMachine is a machine whose memory holds arbitrary precision values, so
+// ADD and MUL never overflow.  Addresses, jump targets and opcodes must still
+// fit in an int.  Values in memory are never modified, only replaced.
+type bigMachine struct {
+	memory       []*big.Int
+	ip           int
+	relativeBase int
+
+	limits  resources
+	usage   resources
+	touched map[int]bool
+}
+
+func newBigMachine(program []*big.Int) *bigMachine {
+	return &bigMachine{
+		memory:  append([]*big.Int{}, program...),
+		touched: make(map[int]bool),
+	}
+}
+
+var bigZero = big.NewInt(0)
+var bigOne = big.NewInt(1)
+
+// small converts a value used as an address or instruction to an int
+func (m *bigMachine) small(val *big.Int) (int, error) {
+	if !val.IsInt64() || int64(int(val.Int64())) != val.Int64() {
+		return 0, fmt.Errorf("%w: %v used as an address at %d", errOverflow, val, m.ip)
+	}
+	return int(val.Int64()), nil
+}
+
+func (m *bigMachine) touch(addr int) error {
+	if m.touched[addr] {
+		return nil
+	}
+	if m.limits.memory > 0 && m.usage.memory >= m.limits.memory {
+		return fmt.Errorf("%w: more than %d memory cells at %d", errBudgetExceeded, m.limits.memory, m.ip)
+	}
+	m.touched[addr] = true
+	m.usage.memory++
+	return nil
+}
+
+func (m *bigMachine) read(addr int) (*big.Int, error) {
+	if addr < 0 {
+		return nil, fmt.Errorf("read from negative address %d at %d", addr, m.ip)
+	}
+	if err := m.touch(addr); err != nil {
+		return nil, err
+	}
+	if addr >= len(m.memory) || m.memory[addr] == nil {
+		return bigZero, nil
+	}
+	return m.memory[addr], nil
+}
+
+func (m *bigMachine) write(addr int, val *big.Int) error {
+	if addr < 0 {
+		return fmt.Errorf("write to negative address %d at %d", addr, m.ip)
+	}
+	if err := m.touch(addr); err != nil {
+		return err
+	}
+	if addr >= len(m.memory) {
+		if err := checkGrowth(len(m.memory), addr, m.limits, m.ip); err != nil {
+			return err
+		}
+		m.memory = append(m.memory, make([]*big.Int, addr-len(m.memory)+1)...)
+	}
+	m.memory[addr] = val
+	return nil
+}
+
+func (m *bigMachine) address(n int, modes [3]int) (int, error) {
+	val, err := m.read(m.ip + n)
+	if err != nil {
+		return 0, err
+	}
+	addr, err := m.small(val)
+	if err != nil {
+		return 0, err
+	}
+	switch modes[n-1] {
+	case positionMode:
+		return addr, nil
+	case relativeMode:
+		return m.relativeBase + addr, nil
+	}
+	return 0, fmt.Errorf("bad mode %d for parameter %d at %d", modes[n-1], n, m.ip)
+}
+
+func (m *bigMachine) params(count int, modes [3]int) ([]*big.Int, error) {
+	vals := make([]*big.Int, count)
+	for n := range vals {
+		var val *big.Int
+		var err error
+		if modes[n] == immediateMode {
+			val, err = m.read(m.ip + n + 1)
+		} else {
+			var addr int
+			if addr, err = m.address(n+1, modes); err == nil {
+				val, err = m.read(addr)
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+		vals[n] = val
+	}
+	return vals, nil
+}
+
+// run executes the program like machine.run, but with arbitrary precision values
+func (m *bigMachine) run(input <-chan *big.Int, output chan<- *big.Int) error {
+
+	defer close(output)
+
+	for {
+		if m.limits.steps > 0 && m.usage.steps >= m.limits.steps {
+			return fmt.Errorf("%w: more than %d steps at %d", errBudgetExceeded, m.limits.steps, m.ip)
+		}
+		m.usage.steps++
+		val, err := m.read(m.ip)
+		if err != nil {
+			return err
+		}
+		instruction, err := m.small(val)
+		if err != nil {
+			return err
+		}
+		opcode, modes := decode(instruction)
+		switch opcode {
+		case 1, 2, 7, 8: // ADD, MUL, LT, EQ
+			p, err := m.params(2, modes)
+			if err != nil {
+				return err
+			}
+			val := bigZero
+			switch {
+			case opcode == 1:
+				val = new(big.Int).Add(p[0], p[1])
+			case opcode == 2:
+				val = new(big.Int).Mul(p[0], p[1])
+			case opcode == 7 && p[0].Cmp(p[1]) < 0, opcode == 8 && p[0].Cmp(p[1]) == 0:
+				val = bigOne
+			}
+			addr, err := m.address(3, modes)
+			if err != nil {
+				return err
+			}
+			if err := m.write(addr, val); err != nil {
+				return err
+			}
+			m.ip += 4
+		case 3: // INP
+			addr, err := m.address(1, modes)
+			if err != nil {
+				return err
+			}
+			val, ok := <-input
+			if !ok {
+				return fmt.Errorf("%w at %d", errInputExhausted, m.ip)
+			}
+			if err := m.write(addr, val); err != nil {
+				return err
+			}
+			m.ip += 2
+		case 4: // OUTP
+			p, err := m.params(1, modes)
+			if err != nil {
+				return err
+			}
+			if m.limits.outputs > 0 && m.usage.outputs >= m.limits.outputs {
+				return fmt.Errorf("%w: more than %d outputs at %d", errBudgetExceeded, m.limits.outputs, m.ip)
+			}
+			m.usage.outputs++
+			output <- p[0]
+			m.ip += 2
+		case 5, 6: // JNZ, JZ
+			p, err := m.params(2, modes)
+			if err != nil {
+				return err
+			}
+			if (opcode == 5) == (p[0].Sign() != 0) {
+				if m.ip, err = m.small(p[1]); err != nil {
+					return err
+				}
+			} else {
+				m.ip += 3
+			}
+		case 9: // ARB
+			p, err := m.params(1, modes)
+			if err != nil {
+				return err
+			}
+			offset, err := m.small(p[0])
+			if err != nil {
+				return err
+			}
+			m.relativeBase += offset
+			m.ip += 2
+		case 99: // EXT
+			return nil
+		default:
+			return fmt.Errorf("error token at %d: %d", m.ip, instruction)
+		}
+	}
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"errors"
+	"math/big"
+	"reflect"
+	"testing"
+)
+
+// The example programs published with the puzzles, run against the shared machine.
+
+// day 2: memory after halting
+func TestConformanceMemory(t *testing.T) {
+	cases := []struct {
+		program, memory []int
+	}{
+		{[]int{1, 9, 10, 3, 2, 3, 11, 0, 99, 30, 40, 50}, []int{3500, 9, 10, 70, 2, 3, 11, 0, 99, 30, 40, 50}},
+		{[]int{1, 0, 0, 0, 99}, []int{2, 0, 0, 0, 99}},
+		{[]int{2, 3, 0, 3, 99}, []int{2, 3, 0, 6, 99}},
+		{[]int{2, 4, 4, 5, 99, 0}, []int{2, 4, 4, 5, 99, 9801}},
+		{[]int{1, 1, 1, 4, 99, 5, 6, 0, 99}, []int{30, 1, 1, 4, 2, 5, 6, 0, 99}},
+		// day 5: parameter modes and negative values
+		{[]int{1002, 4, 3, 4, 33}, []int{1002, 4, 3, 4, 99}},
+		{[]int{1101, 100, -1, 4, 0}, []int{1101, 100, -1, 4, 99}},
+	}
+	for _, c := range cases {
+		m := newMachine(c.program)
+		if _, err := m.runSlice(nil); err != nil {
+			t.Errorf("%v: %v", c.program, err)
+			continue
+		}
+		if !reflect.DeepEqual(m.memory, c.memory) {
+			t.Errorf("%v: memory %v, want %v", c.program, m.memory, c.memory)
+		}
+	}
+}
+
+// day 5 and 9: outputs for given inputs
+func TestConformanceOutputs(t *testing.T) {
+	eqPosition := []int{3, 9, 8, 9, 10, 9, 4, 9, 99, -1, 8}
+	ltPosition := []int{3, 9, 7, 9, 10, 9, 4, 9, 99, -1, 8}
+	eqImmediate := []int{3, 3, 1108, -1, 8, 3, 4, 3, 99}
+	ltImmediate := []int{3, 3, 1107, -1, 8, 3, 4, 3, 99}
+	jumpPosition := []int{3, 12, 6, 12, 15, 1, 13, 14, 13, 4, 13, 99, -1, 0, 1, 9}
+	jumpImmediate := []int{3, 3, 1105, -1, 9, 1101, 0, 0, 12, 4, 12, 99, 1}
+	compare8 := []int{3, 21, 1008, 21, 8, 20, 1005, 20, 22, 107, 8, 21, 20, 1006, 20, 31,
+		1106, 0, 36, 98, 0, 0, 1002, 21, 125, 20, 4, 20, 1105, 1, 46, 104,
+		999, 1105, 1, 46, 1101, 1000, 1, 20, 4, 20, 1105, 1, 46, 98, 99}
+	quine := []int{109, 1, 204, -1, 1001, 100, 1, 100, 1008, 100, 16, 101, 1006, 101, 0, 99}
+
+	cases := []struct {
+		name           string
+		program        []int
+		input, outputs []int
+	}{
+		{"echo", []int{3, 0, 4, 0, 99}, []int{42}, []int{42}},
+		{"equal position", eqPosition, []int{8}, []int{1}},
+		{"not equal position", eqPosition, []int{7}, []int{0}},
+		{"less position", ltPosition, []int{7}, []int{1}},
+		{"not less position", ltPosition, []int{8}, []int{0}},
+		{"equal immediate", eqImmediate, []int{8}, []int{1}},
+		{"not equal immediate", eqImmediate, []int{9}, []int{0}},
+		{"less immediate", ltImmediate, []int{-3}, []int{1}},
+		{"not less immediate", ltImmediate, []int{8}, []int{0}},
+		{"jump position zero", jumpPosition, []int{0}, []int{0}},
+		{"jump position nonzero", jumpPosition, []int{5}, []int{1}},
+		{"jump immediate zero", jumpImmediate, []int{0}, []int{0}},
+		{"jump immediate nonzero", jumpImmediate, []int{-5}, []int{1}},
+		{"below 8", compare8, []int{7}, []int{999}},
+		{"equal 8", compare8, []int{8}, []int{1000}},
+		{"above 8", compare8, []int{9}, []int{1001}},
+		{"quine", quine, nil, quine},
+		{"sixteen digits", []int{1102, 34915192, 34915192, 7, 4, 7, 99, 0}, nil, []int{1219070632396864}},
+		{"large number", []int{104, 1125899906842624, 99}, nil, []int{1125899906842624}},
+	}
+	for _, c := range cases {
+		outputs, err := newMachine(c.program).runSlice(c.input)
+		if err != nil {
+			t.Errorf("%s: %v", c.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(outputs, c.outputs) {
+			t.Errorf("%s: outputs %v, want %v", c.name, outputs, c.outputs)
+		}
+	}
+}
+
+// the big machine must agree on the large number programs
+func TestConformanceBig(t *testing.T) {
+	m := newBigMachine([]*big.Int{big.NewInt(1102), big.NewInt(34915192), big.NewInt(34915192),
+		big.NewInt(7), big.NewInt(4), big.NewInt(7), big.NewInt(99), big.NewInt(0)})
+	in := make(chan *big.Int)
+	close(in)
+	out := make(chan *big.Int, 1)
+	if err := m.run(in, out); err != nil {
+		t.Fatal(err)
+	}
+	if v := <-out; v.String() != "1219070632396864" {
+		t.Errorf("got %v", v)
+	}
+}
+
+// amplify chains one machine per phase like day 7, optionally feeding the last
+// output back to the first machine, and returns the final signal
+func amplify(program []int, phases []int, feedback bool) (int, error) {
+	pipe := make([]chan int, len(phases)+1)
+	for i := range pipe {
+		pipe[i] = make(chan int, 2)
+	}
+	errs := make(chan error, len(phases))
+	for i, phase := range phases {
+		pipe[i] <- phase
+		go func(i int) {
+			errs <- newMachine(program).runChannels(pipe[i], pipe[i+1])
+		}(i)
+	}
+	pipe[0] <- 0
+
+	var signal int
+	for v := range pipe[len(phases)] {
+		signal = v
+		if feedback {
+			// the first machine may already have halted on the last round
+			select {
+			case pipe[0] <- v:
+			default:
+			}
+		}
+	}
+	for range phases {
+		if err := <-errs; err != nil {
+			return 0, err
+		}
+	}
+	return signal, nil
+}
+
+// day 7: amplifier chains, with and without feedback
+func TestConformanceAmplifiers(t *testing.T) {
+	cases := []struct {
+		program  []int
+		phases   []int
+		feedback bool
+		signal   int
+	}{
+		{[]int{3, 15, 3, 16, 1002, 16, 10, 16, 1, 16, 15, 15, 4, 15, 99, 0, 0},
+			[]int{4, 3, 2, 1, 0}, false, 43210},
+		{[]int{3, 23, 3, 24, 1002, 24, 10, 24, 1002, 23, -1, 23, 101, 5, 23, 23, 1, 24, 23, 23, 4, 23, 99, 0, 0},
+			[]int{0, 1, 2, 3, 4}, false, 54321},
+		{[]int{3, 31, 3, 32, 1002, 32, 10, 32, 1001, 31, -2, 31, 1007, 31, 0, 33,
+			1002, 33, 7, 33, 1, 33, 31, 31, 1, 32, 31, 31, 4, 31, 99, 0, 0, 0},
+			[]int{1, 0, 4, 3, 2}, false, 65210},
+		{[]int{3, 26, 1001, 26, -4, 26, 3, 27, 1002, 27, 2, 27, 1, 27, 26,
+			27, 4, 27, 1001, 28, -1, 28, 1005, 28, 6, 99, 0, 0, 5},
+			[]int{9, 8, 7, 6, 5}, true, 139629729},
+		{[]int{3, 52, 1001, 52, -5, 52, 3, 53, 1, 52, 56, 54, 1007, 54, 5, 55, 1005, 55, 26, 1001, 54,
+			-5, 54, 1105, 1, 12, 1, 53, 54, 53, 1008, 54, 0, 55, 1001, 55, 1, 55, 2, 53, 55, 53, 4,
+			53, 1001, 56, -1, 56, 1005, 56, 6, 99, 0, 0, 0, 0, 10},
+			[]int{9, 7, 8, 5, 6}, true, 18216},
+	}
+	for _, c := range cases {
+		signal, err := amplify(c.program, c.phases, c.feedback)
+		if err != nil {
+			t.Errorf("phases %v: %v", c.phases, err)
+			continue
+		}
+		if signal != c.signal {
+			t.Errorf("phases %v: signal %d, want %d", c.phases, signal, c.signal)
+		}
+	}
+}
+
+// running out of input under each policy, on a program that echoes twice
+func TestEmptyPolicies(t *testing.T) {
+	echo := []int{3, 0, 4, 0, 3, 0, 4, 0, 99}
+
+	m := newMachine(echo)
+	if _, err := m.runSlice([]int{5}); !errors.Is(err, errInputExhausted) || m.state != stateFaulted {
+		t.Errorf("fault: %v in state %v", err, m.state)
+	}
+
+	m = newMachine(echo)
+	m.onEmpty = pauseOnEmpty
+	in := &sliceInput{[]int{5}}
+	out := &outputRecorder{}
+	if err := m.run(in, out); err != nil || m.state != stateWaiting || m.ip != 4 {
+		t.Errorf("pause: %v in state %v at %d", err, m.state, m.ip)
+	}
+	in.values = append(in.values, 6)
+	if err := m.run(in, out); err != nil || m.state != stateHalted || !reflect.DeepEqual(out.values, []int{5, 6}) {
+		t.Errorf("resume: %v in state %v with outputs %v", err, m.state, out.values)
+	}
+
+	m = newMachine(echo)
+	m.onEmpty, m.fallback = defaultOnEmpty, -1
+	if outputs, err := m.runSlice([]int{5}); err != nil || !reflect.DeepEqual(outputs, []int{5, -1}) {
+		t.Errorf("default: %v with outputs %v", err, outputs)
+	}
+
+	m = newMachine(echo)
+	refill := make(chan int, 1)
+	refill <- 7
+	m.onEmpty, m.refill = blockOnEmpty, refill
+	if outputs, err := m.runSlice([]int{5}); err != nil || !reflect.DeepEqual(outputs, []int{5, 7}) {
+		t.Errorf("block: %v with outputs %v", err, outputs)
+	}
+}
+
+// writes far past the program fail with a budget error instead of allocating
+func TestFarWrite(t *testing.T) {
+	far := []int{1101, 1, 1, 1 << 60, 99}
+	for _, limit := range []int{0, 10} {
+		m := newMachine(far)
+		m.limits.memory = limit
+		if _, err := m.runSlice(nil); !errors.Is(err, errBudgetExceeded) {
+			t.Errorf("memory limit %d: got %v", limit, err)
+		}
+	}
+}
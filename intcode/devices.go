@@ -0,0 +1,197 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math/rand"
+	"strconv"
+	"strings"
+)
+
+// device is a peripheral mapped into a machine's memory.  Reads and writes to
+// its addresses go to the device instead of memory, offset from its base.
+type device interface {
+	load(offset int) int
+	store(offset int, val int)
+}
+
+// mapping reserves size addresses starting at base for a device
+type mapping struct {
+	base, size int
+	dev        device
+}
+
+// attach maps d over [base, base+size) of m's memory
+func (m *machine) attach(base int, size int, d device) error {
+	if base < 0 || size < 1 {
+		return fmt.Errorf("bad device range %d+%d", base, size)
+	}
+	for _, other := range m.devices {
+		if base < other.base+other.size && other.base < base+size {
+			return fmt.Errorf("device at %d overlaps device at %d", base, other.base)
+		}
+	}
+	m.devices = append(m.devices, mapping{base, size, d})
+	return nil
+}
+
+// device returns the device mapped at addr, if any, and the offset into it
+func (m *machine) device(addr int) (device, int) {
+	for _, d := range m.devices {
+		if addr >= d.base && addr < d.base+d.size {
+			return d.dev, addr - d.base
+		}
+	}
+	return nil, 0
+}
+
+// clock reads as the machine's step count, counting the reading instruction, so
+// runs stay reproducible
+type clock struct {
+	m *machine
+}
+
+func (c clock) load(int) int {
+	return c.m.usage.steps
+}
+
+func (c clock) store(int, int) {}
+
+// random reads as the next value of a seeded generator, storing a value reseeds it
+type random struct {
+	rng *rand.Rand
+}
+
+func newRandom(seed int64) *random {
+	return &random{rand.New(rand.NewSource(seed))}
+}
+
+func (r *random) load(int) int {
+	return int(r.rng.Int31())
+}
+
+func (r *random) store(offset int, val int) {
+	r.rng.Seed(int64(val))
+}
+
+type dimension struct {
+	width, height int
+}
+
+func (d dimension) size() int {
+	return d.width * d.height
+}
+
+// framebuffer maps a grid of pixels, row by row
+type framebuffer struct {
+	bounds dimension
+	pixels []int
+}
+
+func newFramebuffer(bounds dimension) *framebuffer {
+	return &framebuffer{bounds: bounds, pixels: make([]int, bounds.size())}
+}
+
+func (f *framebuffer) load(offset int) int {
+	return f.pixels[offset]
+}
+
+func (f *framebuffer) store(offset int, val int) {
+	f.pixels[offset] = val
+}
+
+// render draws the framebuffer like day 8's renderImage
+func (f *framebuffer) render(w io.Writer) {
+	for row := 0; row < f.bounds.height; row++ {
+		for col := 0; col < f.bounds.width; col++ {
+			if f.pixels[col+row*f.bounds.width] == 1 {
+				fmt.Fprintf(w, "*")
+			} else {
+				fmt.Fprintf(w, " ")
+			}
+		}
+		fmt.Fprintf(w, "\n")
+	}
+}
+
+// keyboard queues key codes.  Reading offset 0 takes the next key, or -1 when
+// the queue is empty, reading offset 1 gives the number of keys waiting and
+// storing to offset 0 queues a key.
+type keyboard struct {
+	keys []int
+}
+
+func (k *keyboard) load(offset int) int {
+	if offset == 1 {
+		return len(k.keys)
+	}
+	if len(k.keys) == 0 {
+		return -1
+	}
+	key := k.keys[0]
+	k.keys = k.keys[1:]
+	return key
+}
+
+func (k *keyboard) store(offset int, val int) {
+	if offset == 0 {
+		k.keys = append(k.keys, val)
+	}
+}
+
+// attachDevice parses a device flag of the form kind@addr[:arg] and maps it into m:
+//
+//	clock@addr               step count of the machine
+//	random@addr[:seed]       seeded random numbers, seed defaults to 1
+//	screen@addr:WxH          framebuffer of W by H pixels
+//	keyboard@addr[:text]     key queue, preloaded with the characters of text
+func attachDevice(m *machine, spec string) (*framebuffer, error) {
+	kind, rest, ok := strings.Cut(spec, "@")
+	if !ok {
+		return nil, fmt.Errorf("device %q is not kind@addr[:arg]", spec)
+	}
+	addrText, arg, _ := strings.Cut(rest, ":")
+	addr, err := strconv.Atoi(addrText)
+	if err != nil {
+		return nil, fmt.Errorf("device %q has bad address %q", spec, addrText)
+	}
+
+	switch kind {
+	case "clock":
+		return nil, m.attach(addr, 1, clock{m})
+	case "random":
+		seed := int64(1)
+		if arg != "" {
+			if seed, err = strconv.ParseInt(arg, 10, 64); err != nil {
+				return nil, fmt.Errorf("device %q has bad seed %q", spec, arg)
+			}
+		}
+		return nil, m.attach(addr, 1, newRandom(seed))
+	case "screen":
+		var bounds dimension
+		if _, err := fmt.Sscanf(arg, "%dx%d", &bounds.width, &bounds.height); err != nil || bounds.size() < 1 {
+			return nil, fmt.Errorf("device %q needs a size like 40x20", spec)
+		}
+		f := newFramebuffer(bounds)
+		return f, m.attach(addr, bounds.size(), f)
+	case "keyboard":
+		k := &keyboard{}
+		for _, r := range arg {
+			k.keys = append(k.keys, int(r))
+		}
+		return nil, m.attach(addr, 2, k)
+	}
+	return nil, fmt.Errorf("unknown device %q", kind)
+}
+
+// deviceList collects repeated -device flags
+type deviceList []string
+
+func (l *deviceList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *deviceList) Set(text string) error {
+	*l = append(*l, text)
+	return nil
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// instruction names and parameter counts, indexed by opcode
+var mnemonics = map[int]struct {
+	name   string
+	params int
+}{
+	1:  {"ADD", 3},
+	2:  {"MUL", 3},
+	3:  {"INP", 1},
+	4:  {"OUTP", 1},
+	5:  {"JNZ", 2},
+	6:  {"JZ", 2},
+	7:  {"LT", 3},
+	8:  {"EQ", 3},
+	9:  {"ARB", 1},
+	99: {"EXT", 0},
+}
+
+// cell returns memory[addr], reading past the end as zero
+func cell(memory []int, addr int) int {
+	if addr < len(memory) {
+		return memory[addr]
+	}
+	return 0
+}
+
+// disassemble decodes the instruction at addr, returning its text and length.
+// Values that aren't instructions, or whose parameters run off the end of
+// memory or use a bad mode, come back as a single DATA cell.
+func disassemble(memory []int, addr int) (string, int) {
+	opcode, modes := decode(cell(memory, addr))
+	m, ok := mnemonics[opcode]
+	if !ok || addr+m.params >= len(memory) || cell(memory, addr) < 0 {
+		return fmt.Sprintf("DATA %d", cell(memory, addr)), 1
+	}
+	params := make([]string, m.params)
+	for n := range params {
+		val := memory[addr+n+1]
+		switch modes[n] {
+		case positionMode:
+			params[n] = fmt.Sprintf("[%d]", val)
+		case immediateMode:
+			params[n] = fmt.Sprintf("%d", val)
+		case relativeMode:
+			params[n] = fmt.Sprintf("[rb%+d]", val)
+		default:
+			return fmt.Sprintf("DATA %d", cell(memory, addr)), 1
+		}
+	}
+	if len(params) == 0 {
+		return m.name, 1
+	}
+	return m.name + " " + strings.Join(params, ", "), m.params + 1
+}
+
+// diffPrograms reports every cell that differs between two program images or
+// memory dumps.  Cells are grouped by the instructions of a, decoded from
+// address 0, so a change to an operand is shown as a change of instruction.
+// It returns the number of differing cells.
+func diffPrograms(w io.Writer, a []int, b []int) int {
+	size := len(a)
+	if len(b) > size {
+		size = len(b)
+	}
+	if len(a) != len(b) {
+		fmt.Fprintf(w, "length %d -> %d\n", len(a), len(b))
+	}
+
+	changed := 0
+	for addr := 0; addr < size; {
+		before, length := disassemble(a, addr)
+		differs := 0
+		for i := addr; i < addr+length; i++ {
+			if cell(a, i) != cell(b, i) {
+				differs++
+			}
+		}
+		if differs > 0 {
+			after, afterLength := disassemble(b, addr)
+			if length == 1 && afterLength == 1 {
+				fmt.Fprintf(w, "%d: %d -> %d\n", addr, cell(a, addr), cell(b, addr))
+			} else {
+				fmt.Fprintf(w, "%d-%d: %s\n", addr, addr+length-1, before)
+				fmt.Fprintf(w, "%*s-> %s\n", len(fmt.Sprintf("%d-%d", addr, addr+length-1)), "", after)
+			}
+			changed += differs
+		}
+		addr += length
+	}
+	fmt.Fprintf(w, "%d cells differ\n", changed)
+	return changed
+}
@@ -0,0 +1,393 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"reflect"
+	"testing"
+)
+
+// Differential testing: every interpreter in this directory runs the same
+// program and must agree on outputs, final memory and whether it faulted.
+// Run the fuzzer with
+//
+//	go test -run XXX -fuzz FuzzInterpreters
+
+const (
+	fuzzSteps   = 1000    // instruction budget for every run
+	fuzzAddress = 1 << 12 // programs touching memory past this are skipped
+)
+
+// result is what every interpreter must agree on
+type result struct {
+	outputs []int
+	memory  []int
+	fault   bool
+	budget  bool // the fault was running out of steps
+}
+
+func (r result) String() string {
+	return fmt.Sprintf("outputs=%v fault=%v budget=%v memory=%v", r.outputs, r.fault, r.budget, r.memory)
+}
+
+// trimmed drops trailing zeros, since interpreters may grow memory differently
+func trimmed(memory []int) []int {
+	n := len(memory)
+	for n > 0 && memory[n-1] == 0 {
+		n--
+	}
+	return append([]int{}, memory[:n]...)
+}
+
+func newResult(outputs []int, memory []int, err error) result {
+	if outputs == nil {
+		outputs = []int{}
+	}
+	return result{
+		outputs: outputs,
+		memory:  trimmed(memory),
+		fault:   err != nil,
+		budget:  errors.Is(err, errBudgetExceeded),
+	}
+}
+
+// reference is a deliberately plain interpreter in the shape of day 5's
+// executeProgram, extended with relative mode and faults instead of log lines.
+// It also reports whether the run strayed outside what the other interpreters
+// can be compared on: addresses past fuzzAddress, or ADD/MUL overflowing an int.
+func reference(programData []int, input []int) (res result, wild bool, overflow bool) {
+	mem := append([]int{}, programData...)
+	output := make([]int, 0)
+	fault := func(budget bool) (result, bool, bool) {
+		return result{outputs: output, memory: trimmed(mem), fault: true, budget: budget}, wild, overflow
+	}
+	get := func(addr int) (int, bool) {
+		if addr < 0 {
+			return 0, false
+		}
+		if addr >= fuzzAddress {
+			wild = true
+			return 0, false
+		}
+		if addr >= len(mem) {
+			return 0, true
+		}
+		return mem[addr], true
+	}
+	set := func(addr, val int) bool {
+		if addr < 0 {
+			return false
+		}
+		if addr >= fuzzAddress {
+			wild = true
+			return false
+		}
+		for addr >= len(mem) {
+			mem = append(mem, 0)
+		}
+		mem[addr] = val
+		return true
+	}
+
+	i, base := 0, 0
+	for steps := 0; ; steps++ {
+		if steps == fuzzSteps {
+			return fault(true)
+		}
+		instruction, ok := get(i)
+		if !ok {
+			return fault(false)
+		}
+		opcode, modes := decode(instruction)
+		// addr resolves parameter n, which must not be in immediate mode
+		addr := func(n int) (int, bool) {
+			v, ok := get(i + n)
+			switch {
+			case !ok:
+				return 0, false
+			case modes[n-1] == 0:
+				return v, true
+			case modes[n-1] == 2:
+				return base + v, true
+			}
+			return 0, false
+		}
+		param := func(n int) (int, bool) {
+			if modes[n-1] == 1 {
+				return get(i + n)
+			}
+			a, ok := addr(n)
+			if !ok {
+				return 0, false
+			}
+			return get(a)
+		}
+
+		switch opcode {
+		case 1, 2, 7, 8: // ADD, MUL, LT, EQ
+			a, ok1 := param(1)
+			b, ok2 := param(2)
+			dest, ok3 := addr(3)
+			if !ok1 || !ok2 || !ok3 {
+				return fault(false)
+			}
+			var val int
+			var err error
+			switch opcode {
+			case 1:
+				val, err = checkedAdd(a, b)
+				if err != nil {
+					overflow = true
+					val = a + b
+				}
+			case 2:
+				val, err = checkedMul(a, b)
+				if err != nil {
+					overflow = true
+					val = a * b
+				}
+			case 7:
+				if a < b {
+					val = 1
+				}
+			case 8:
+				if a == b {
+					val = 1
+				}
+			}
+			if !set(dest, val) {
+				return fault(false)
+			}
+			i += 4
+		case 3: // INP
+			dest, ok := addr(1)
+			if !ok || len(input) == 0 || !set(dest, input[0]) {
+				return fault(false)
+			}
+			input = input[1:]
+			i += 2
+		case 4: // OUTP
+			v, ok := param(1)
+			if !ok {
+				return fault(false)
+			}
+			output = append(output, v)
+			i += 2
+		case 5, 6: // JNZ, JZ
+			v, ok1 := param(1)
+			target, ok2 := param(2)
+			if !ok1 || !ok2 {
+				return fault(false)
+			}
+			if (opcode == 5) == (v != 0) {
+				i = target
+			} else {
+				i += 3
+			}
+		case 9: // ARB
+			v, ok := param(1)
+			if !ok {
+				return fault(false)
+			}
+			if _, err := checkedAdd(base, v); err != nil {
+				overflow = true
+			}
+			base += v
+			i += 2
+		case 99: // EXT
+			return result{outputs: output, memory: trimmed(mem)}, wild, overflow
+		default:
+			return fault(false)
+		}
+	}
+}
+
+func runMachine(program []int, input []int, checked bool) result {
+	m := newMachine(program)
+	m.limits.steps = fuzzSteps
+	m.checked = checked
+	outputs, err := m.runSlice(input)
+	return newResult(outputs, m.memory, err)
+}
+
+// runChannelMachine feeds the machine through channels like day 7's
+// executeProgramChannel, collecting outputs until it closes the channel
+func runChannelMachine(program []int, input []int) result {
+	m := newMachine(program)
+	m.limits.steps = fuzzSteps
+
+	in := make(chan int, len(input))
+	for _, v := range input {
+		in <- v
+	}
+	close(in)
+	out := make(chan int)
+	done := make(chan error, 1)
+	go func() {
+		done <- m.runChannels(in, out)
+	}()
+	outputs := make([]int, 0)
+	for v := range out {
+		outputs = append(outputs, v)
+	}
+	return newResult(outputs, m.memory, <-done)
+}
+
+func runBigMachine(program []int, input []int) result {
+	bigProgram := make([]*big.Int, len(program))
+	for i, v := range program {
+		bigProgram[i] = big.NewInt(int64(v))
+	}
+	m := newBigMachine(bigProgram)
+	m.limits.steps = fuzzSteps
+
+	in := make(chan *big.Int, len(input))
+	for _, v := range input {
+		in <- big.NewInt(int64(v))
+	}
+	close(in)
+	out := make(chan *big.Int)
+	done := make(chan error)
+	go func() {
+		done <- m.run(in, out)
+	}()
+	outputs := make([]int, 0)
+	for v := range out {
+		outputs = append(outputs, int(v.Int64()))
+	}
+	err := <-done
+
+	memory := make([]int, len(m.memory))
+	for i, v := range m.memory {
+		if v != nil {
+			memory[i] = int(v.Int64())
+		}
+	}
+	return newResult(outputs, memory, err)
+}
+
+// runSymbolic runs the symbolic interpreter with no symbols, so it stays concrete
+func runSymbolic(program []int, input []int) result {
+	r := symbolicRun{program: program, inputs: input, symbols: map[string]variable{}, maxPaths: 1, maxSteps: fuzzSteps}
+	if err := r.explore(); err != nil {
+		return result{fault: true}
+	}
+	paths := append(r.halted, r.failed...)
+	if len(paths) != 1 {
+		return result{fault: true}
+	}
+	s := paths[0]
+	outputs := make([]int, len(s.outputs))
+	for i, o := range s.outputs {
+		outputs[i], _ = o.value()
+	}
+	memory := make([]int, len(s.memory))
+	for i, cell := range s.memory {
+		memory[i], _ = cell.value()
+	}
+	return newResult(outputs, memory, s.err)
+}
+
+// fuzzProgram turns fuzzer bytes into a program.  Bytes below 0x80 become
+// instructions with arbitrary parameter modes, the rest small operands, so
+// most programs get further than their first instruction.
+func fuzzProgram(data []byte) []int {
+	opcodes := [...]int{1, 2, 3, 4, 5, 6, 7, 8, 9, 99}
+	program := make([]int, len(data))
+	for i, b := range data {
+		if b < 0x80 {
+			modes := int(b/10)%3*100 + int(b/30)%3*1000 + int(b/90)%3*10000
+			program[i] = modes + opcodes[b%10]
+		} else {
+			program[i] = int(b&0x7f) - 16
+		}
+	}
+	return program
+}
+
+func fuzzInputs(data []byte) []int {
+	inputs := make([]int, len(data))
+	for i, b := range data {
+		inputs[i] = int(int8(b))
+	}
+	return inputs
+}
+
+// differ runs every interpreter and reports the first disagreement with the reference
+func differ(program []int, input []int) error {
+	want, wild, overflow := reference(program, input)
+	if wild {
+		return nil
+	}
+
+	got := map[string]result{
+		"symbolic": runSymbolic(program, input),
+	}
+	// the machine wraps on overflow, so only the checked machine and the big
+	// machine disagree with the reference when it overflowed
+	if !overflow {
+		got["machine"] = runMachine(program, input, false)
+		got["channels"] = runChannelMachine(program, input)
+		got["checked"] = runMachine(program, input, true)
+		got["big"] = runBigMachine(program, input)
+	} else {
+		got["machine"] = runMachine(program, input, false)
+		got["channels"] = runChannelMachine(program, input)
+		if checked := runMachine(program, input, true); !checked.fault {
+			return fmt.Errorf("checked machine missed an overflow: %v", checked)
+		}
+	}
+
+	for name, res := range got {
+		if !reflect.DeepEqual(res, want) {
+			return fmt.Errorf("%s: got %v, reference %v", name, res, want)
+		}
+	}
+	return nil
+}
+
+var fuzzSeeds = []struct {
+	program, input []byte
+}{
+	{[]byte{3, 0x80, 4, 0x80, 99}, []byte{7}},
+	{[]byte{1, 0x80 + 16 + 5, 0x80 + 16 + 6, 0x80 + 16 + 0, 99, 0x80 + 16 + 30, 0x80 + 16 + 40}, nil},
+	{[]byte{5 + 30, 0x80 + 16 + 1, 0x80 + 16 + 0}, nil}, // JNZ back to itself until the budget runs out
+	{[]byte{2 + 10 + 30, 0x80 + 127, 0x80 + 127, 0x80 + 16 + 9, 4, 0x80 + 16 + 9, 99}, nil},
+	{[]byte{9 + 10, 0x80 + 16 + 3, 4 + 20, 0x80, 99}, nil},
+	{[]byte{3, 0x80}, nil}, // input exhausted
+	{[]byte{42}, nil},
+}
+
+func TestDifferential(t *testing.T) {
+	for i, seed := range fuzzSeeds {
+		if err := differ(fuzzProgram(seed.program), fuzzInputs(seed.input)); err != nil {
+			t.Errorf("seed %d: %v", i, err)
+		}
+	}
+}
+
+func FuzzInterpreters(f *testing.F) {
+	for _, seed := range fuzzSeeds {
+		f.Add(seed.program, seed.input)
+	}
+	f.Fuzz(func(t *testing.T, programData []byte, inputData []byte) {
+		program := fuzzProgram(programData)
+		input := fuzzInputs(inputData)
+		if err := differ(program, input); err != nil {
+			t.Errorf("program %v input %v: %v", program, input, err)
+		}
+	})
+}
+
+// FuzzRawPrograms feeds unrestricted values, which mostly exercises faults
+func FuzzRawPrograms(f *testing.F) {
+	f.Add(int64(1101), int64(1), int64(2), int64(0), int64(99))
+	f.Add(int64(3), int64(0), int64(4), int64(0), int64(99))
+	f.Fuzz(func(t *testing.T, a, b, c, d, e int64) {
+		program := []int{int(a), int(b), int(c), int(d), int(e)}
+		if err := differ(program, []int{int(a)}); err != nil {
+			t.Errorf("program %v: %v", program, err)
+		}
+	})
+}
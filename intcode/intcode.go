@@ -0,0 +1,471 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Define a split function that separates on commas. (stolen from https://golang.org/src/bufio/example_test.go)
+func commaSplit(data []byte, atEOF bool) (advance int, token []byte, err error) {
+	for i := 0; i < len(data); i++ {
+		if data[i] == ',' {
+			return i + 1, data[:i], nil
+		}
+	}
+	if !atEOF {
+		return 0, nil, nil
+	}
+	// There is one final token to be delivered, which may be the empty string.
+	// Returning bufio.ErrFinalToken here tells Scan there are no more tokens after this
+	// but does not trigger an error to be returned from Scan itself.
+	return 0, data, bufio.ErrFinalToken
+}
+
+// readTokens reads the comma separated values of a program as text
+func readTokens(r io.Reader) ([]string, error) {
+	tokens := make([]string, 0, 1<<10)
+	scanner := bufio.NewScanner(r)
+	scanner.Split(commaSplit)
+	for scanner.Scan() {
+		token := strings.TrimSpace(scanner.Text())
+		if token != "" {
+			tokens = append(tokens, token)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return tokens, nil
+}
+
+// parseProgram reads a comma separated program
+func parseProgram(r io.Reader) ([]int, error) {
+	tokens, err := readTokens(r)
+	if err != nil {
+		return nil, err
+	}
+	data := make([]int, len(tokens))
+	for i, token := range tokens {
+		if data[i], err = strconv.Atoi(token); err != nil {
+			return nil, fmt.Errorf("bad value %q at position %d", token, i)
+		}
+	}
+	return data, nil
+}
+
+func openProgram(filename string, parse func(io.Reader) error) error {
+	file, err := os.Open(filename)
+	if err != nil {
+		return fmt.Errorf("error opening file %s: %v", filename, err)
+	}
+	defer file.Close()
+
+	return parse(file)
+}
+
+func loadProgram(filename string) (program []int, err error) {
+	err = openProgram(filename, func(r io.Reader) error {
+		program, err = parseProgram(r)
+		return err
+	})
+	return program, err
+}
+
+func decode(instruction int) (opcode int, modes [3]int) {
+	opcode = instruction % 100
+	modes[0] = instruction / 100 % 10
+	modes[1] = instruction / 1000 % 10
+	modes[2] = instruction / 10000 % 10
+	return opcode, modes
+}
+
+// parameter modes
+const (
+	positionMode  = 0
+	immediateMode = 1
+	relativeMode  = 2
+)
+
+// errOverflow is wrapped by every error from checked arithmetic
+var errOverflow = errors.New("integer overflow")
+
+// checkedAdd adds a and b, failing instead of wrapping around
+func checkedAdd(a, b int) (int, error) {
+	sum := a + b
+	if (a > 0 && b > 0 && sum < 0) || (a < 0 && b < 0 && sum >= 0) {
+		return 0, fmt.Errorf("%w: %d + %d", errOverflow, a, b)
+	}
+	return sum, nil
+}
+
+// checkedMul multiplies a and b, failing instead of wrapping around
+func checkedMul(a, b int) (int, error) {
+	product := a * b
+	if a != 0 && (product/a != b || (a == -1 && b != 0 && b == -b)) {
+		return 0, fmt.Errorf("%w: %d * %d", errOverflow, a, b)
+	}
+	return product, nil
+}
+
+// errInputExhausted is wrapped by the error from reading past the last input
+var errInputExhausted = errors.New("input exhausted")
+
+// errBudgetExceeded is wrapped by every error caused by running out of a limit
+var errBudgetExceeded = errors.New("budget exceeded")
+
+// maxCells bounds the memory a machine will grow to when it has no memory budget
+const maxCells = 1 << 26
+
+// checkGrowth fails rather than growing memory of length cells far enough to
+// hold addr, when that would allocate more cells than the memory budget
+// allows or more than maxCells in all
+func checkGrowth(length int, addr int, limits resources, ip int) error {
+	grow := addr - length + 1
+	if limits.memory > 0 && grow > limits.memory {
+		return fmt.Errorf("%w: growing memory by %d cells to write to %d at %d", errBudgetExceeded, grow, addr, ip)
+	}
+	if addr >= maxCells {
+		return fmt.Errorf("%w: write to %d past %d memory cells at %d", errBudgetExceeded, addr, maxCells, ip)
+	}
+	return nil
+}
+
+// resources counts instructions executed, distinct memory cells touched and
+// values output.  It is used both for limits, where zero means unlimited, and
+// for the usage accumulated by a machine.
+type resources struct {
+	steps, memory, outputs int
+}
+
+func (r resources) String() string {
+	return fmt.Sprintf("steps=%d memory=%d outputs=%d", r.steps, r.memory, r.outputs)
+}
+
+// state says whether a machine can keep running
+type state int
+
+const (
+	stateRunning state = iota
+	stateWaiting       // paused on INP with no input, step again to resume
+	stateHalted
+	stateFaulted
+)
+
+var stateNames = [...]string{"running", "waiting for input", "halted", "faulted"}
+
+func (s state) String() string {
+	return stateNames[s]
+}
+
+// emptyPolicy decides what INP does when its Input has run out
+type emptyPolicy int
+
+const (
+	faultOnEmpty   emptyPolicy = iota // stop with an errInputExhausted error
+	pauseOnEmpty                      // enter stateWaiting, leaving ip on the INP
+	defaultOnEmpty                    // read the machine's fallback value instead
+	blockOnEmpty                      // wait for a value on the machine's refill channel
+)
+
+var policyNames = map[string]emptyPolicy{
+	"fault":   faultOnEmpty,
+	"pause":   pauseOnEmpty,
+	"default": defaultOnEmpty,
+	"block":   blockOnEmpty,
+}
+
+// machine holds the state of a single intcode computer
+type machine struct {
+	memory       []int
+	ip           int
+	relativeBase int
+	state        state
+	fault        error
+
+	// onEmpty with its fallback value or refill channel handles running out of input
+	onEmpty  emptyPolicy
+	fallback int
+	refill   <-chan int
+
+	limits  resources
+	usage   resources
+	touched map[int]bool
+
+	// checked makes ADD, MUL and ARB fail with errOverflow rather than wrap
+	checked bool
+
+	devices []mapping
+}
+
+// newMachine copies the program into fresh memory
+func newMachine(program []int) *machine {
+	return &machine{
+		memory:  append([]int{}, program...),
+		touched: make(map[int]bool),
+	}
+}
+
+// touch records an access to addr against the memory budget
+func (m *machine) touch(addr int) error {
+	if m.touched[addr] {
+		return nil
+	}
+	if m.limits.memory > 0 && m.usage.memory >= m.limits.memory {
+		return fmt.Errorf("%w: more than %d memory cells at %d", errBudgetExceeded, m.limits.memory, m.ip)
+	}
+	m.touched[addr] = true
+	m.usage.memory++
+	return nil
+}
+
+// read returns the value at addr, memory past the program reads as zero
+func (m *machine) read(addr int) (int, error) {
+	if addr < 0 {
+		return 0, fmt.Errorf("read from negative address %d at %d", addr, m.ip)
+	}
+	if err := m.touch(addr); err != nil {
+		return 0, err
+	}
+	if d, offset := m.device(addr); d != nil {
+		return d.load(offset), nil
+	}
+	if addr >= len(m.memory) {
+		return 0, nil
+	}
+	return m.memory[addr], nil
+}
+
+// write stores val at addr, growing memory as needed
+func (m *machine) write(addr int, val int) error {
+	if addr < 0 {
+		return fmt.Errorf("write to negative address %d at %d", addr, m.ip)
+	}
+	if err := m.touch(addr); err != nil {
+		return err
+	}
+	if d, offset := m.device(addr); d != nil {
+		d.store(offset, val)
+		return nil
+	}
+	if addr >= len(m.memory) {
+		if err := checkGrowth(len(m.memory), addr, m.limits, m.ip); err != nil {
+			return err
+		}
+		m.memory = append(m.memory, make([]int, addr-len(m.memory)+1)...)
+	}
+	m.memory[addr] = val
+	return nil
+}
+
+// address resolves the nth parameter of the current instruction to a memory address
+func (m *machine) address(n int, modes [3]int) (int, error) {
+	val, err := m.read(m.ip + n)
+	if err != nil {
+		return 0, err
+	}
+	switch modes[n-1] {
+	case positionMode:
+		return val, nil
+	case relativeMode:
+		return m.relativeBase + val, nil
+	}
+	return 0, fmt.Errorf("bad mode %d for parameter %d at %d", modes[n-1], n, m.ip)
+}
+
+// param resolves the nth parameter of the current instruction to a value
+func (m *machine) param(n int, modes [3]int) (int, error) {
+	if modes[n-1] == immediateMode {
+		return m.read(m.ip + n)
+	}
+	addr, err := m.address(n, modes)
+	if err != nil {
+		return 0, err
+	}
+	return m.read(addr)
+}
+
+// params resolves the first count parameters of the current instruction
+func (m *machine) params(count int, modes [3]int) ([]int, error) {
+	vals := make([]int, count)
+	for n := range vals {
+		val, err := m.param(n+1, modes)
+		if err != nil {
+			return nil, err
+		}
+		vals[n] = val
+	}
+	return vals, nil
+}
+
+// run executes the program until it halts, faults or pauses for input, reading
+// from input and writing to output.  Running past one of the limits stops the
+// machine with an errBudgetExceeded error.  A paused machine resumes from the
+// same INP when run again.
+func (m *machine) run(input Input, output Output) error {
+	for {
+		halted, err := m.step(input, output)
+		if halted || err != nil || m.state == stateWaiting {
+			return err
+		}
+	}
+}
+
+// step executes a single instruction, reporting whether it halted the machine,
+// and updates the machine's state.  A halted machine stays on its halt
+// instruction, a faulted one keeps returning the error that stopped it.
+func (m *machine) step(input Input, output Output) (bool, error) {
+	switch m.state {
+	case stateHalted:
+		return true, nil
+	case stateFaulted:
+		return false, m.fault
+	}
+	m.state = stateRunning
+	halted, err := m.execute(input, output)
+	switch {
+	case err != nil:
+		m.state, m.fault = stateFaulted, err
+	case halted:
+		m.state = stateHalted
+	}
+	return halted, err
+}
+
+// receive reads the next input, applying the machine's policy once input has
+// run out.  It reports false if the machine should pause instead.
+func (m *machine) receive(input Input) (int, bool, error) {
+	val, err := input.Read()
+	if !errors.Is(err, errInputExhausted) {
+		return val, err == nil, err
+	}
+	switch m.onEmpty {
+	case pauseOnEmpty:
+		return 0, false, nil
+	case defaultOnEmpty:
+		return m.fallback, true, nil
+	case blockOnEmpty:
+		if val, ok := <-m.refill; ok {
+			return val, true, nil
+		}
+	}
+	return 0, false, err
+}
+
+// execute decodes and executes the instruction at ip
+func (m *machine) execute(input Input, output Output) (bool, error) {
+	if m.limits.steps > 0 && m.usage.steps >= m.limits.steps {
+		return false, fmt.Errorf("%w: more than %d steps at %d", errBudgetExceeded, m.limits.steps, m.ip)
+	}
+	m.usage.steps++
+	instruction, err := m.read(m.ip)
+	if err != nil {
+		return false, err
+	}
+	opcode, modes := decode(instruction)
+	switch opcode {
+	case 1, 2, 7, 8: // ADD, MUL, LT, EQ
+		p, err := m.params(2, modes)
+		if err != nil {
+			return false, err
+		}
+		var val int
+		switch {
+		case opcode == 1 && m.checked:
+			val, err = checkedAdd(p[0], p[1])
+		case opcode == 1:
+			val = p[0] + p[1]
+		case opcode == 2 && m.checked:
+			val, err = checkedMul(p[0], p[1])
+		case opcode == 2:
+			val = p[0] * p[1]
+		case opcode == 7 && p[0] < p[1], opcode == 8 && p[0] == p[1]:
+			val = 1
+		}
+		if err != nil {
+			return false, fmt.Errorf("%w at %d", err, m.ip)
+		}
+		addr, err := m.address(3, modes)
+		if err != nil {
+			return false, err
+		}
+		if err := m.write(addr, val); err != nil {
+			return false, err
+		}
+		m.ip += 4
+	case 3: // INP
+		addr, err := m.address(1, modes)
+		if err != nil {
+			return false, err
+		}
+		val, ok, err := m.receive(input)
+		if err != nil {
+			return false, fmt.Errorf("%w at %d", err, m.ip)
+		}
+		if !ok {
+			// try the same instruction again on resuming
+			m.usage.steps--
+			m.state = stateWaiting
+			return false, nil
+		}
+		if err := m.write(addr, val); err != nil {
+			return false, err
+		}
+		m.ip += 2
+	case 4: // OUTP
+		p, err := m.params(1, modes)
+		if err != nil {
+			return false, err
+		}
+		if m.limits.outputs > 0 && m.usage.outputs >= m.limits.outputs {
+			return false, fmt.Errorf("%w: more than %d outputs at %d", errBudgetExceeded, m.limits.outputs, m.ip)
+		}
+		m.usage.outputs++
+		if err := output.Write(p[0]); err != nil {
+			return false, fmt.Errorf("%w at %d", err, m.ip)
+		}
+		m.ip += 2
+	case 5, 6: // JNZ, JZ
+		p, err := m.params(2, modes)
+		if err != nil {
+			return false, err
+		}
+		if (opcode == 5) == (p[0] != 0) {
+			m.ip = p[1]
+		} else {
+			m.ip += 3
+		}
+	case 9: // ARB
+		p, err := m.params(1, modes)
+		if err != nil {
+			return false, err
+		}
+		if m.checked {
+			if m.relativeBase, err = checkedAdd(m.relativeBase, p[0]); err != nil {
+				return false, fmt.Errorf("%w at %d", err, m.ip)
+			}
+		} else {
+			m.relativeBase += p[0]
+		}
+		m.ip += 2
+	case 99: // EXT
+		return true, nil
+	default:
+		return false, fmt.Errorf("error token at %d: %d", m.ip, instruction)
+	}
+	return false, nil
+}
+
+// runSlice runs the machine to completion with a fixed list of inputs, in the
+// style of executeProgram, and returns everything it output
+func (m *machine) runSlice(input []int) ([]int, error) {
+	output := &outputRecorder{values: make([]int, 0)}
+	err := m.run(&sliceInput{input}, output)
+	return output.values, err
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// Input supplies the values read by INP instructions.  Read returns an error
+// wrapping errInputExhausted once there are no more values.
+type Input interface {
+	Read() (int, error)
+}
+
+// Output receives the values written by OUTP instructions.  An error from
+// Write stops the machine.
+type Output interface {
+	Write(val int) error
+}
+
+// InputFunc adapts a callback to an Input
+type InputFunc func() (int, error)
+
+func (f InputFunc) Read() (int, error) {
+	return f()
+}
+
+// OutputFunc adapts a callback to an Output
+type OutputFunc func(val int) error
+
+func (f OutputFunc) Write(val int) error {
+	return f(val)
+}
+
+// sliceInput reads a fixed list of values, like day 5's executeProgram
+type sliceInput struct {
+	values []int
+}
+
+func (s *sliceInput) Read() (int, error) {
+	if len(s.values) == 0 {
+		return 0, errInputExhausted
+	}
+	val := s.values[0]
+	s.values = s.values[1:]
+	return val, nil
+}
+
+// channelInput reads from a channel like day 7's executeProgramChannel,
+// blocking until a value arrives and running out when it is closed
+type channelInput <-chan int
+
+func (c channelInput) Read() (int, error) {
+	val, ok := <-c
+	if !ok {
+		return 0, errInputExhausted
+	}
+	return val, nil
+}
+
+// channelOutput sends each value on a channel
+type channelOutput chan<- int
+
+func (c channelOutput) Write(val int) error {
+	c <- val
+	return nil
+}
+
+// readerInput lazily parses values separated by commas or whitespace from a reader
+type readerInput struct {
+	scanner *bufio.Scanner
+}
+
+func newReaderInput(r io.Reader) *readerInput {
+	scanner := bufio.NewScanner(r)
+	scanner.Split(inputSplit)
+	return &readerInput{scanner}
+}
+
+func (r *readerInput) Read() (int, error) {
+	if !r.scanner.Scan() {
+		if err := r.scanner.Err(); err != nil {
+			return 0, err
+		}
+		return 0, errInputExhausted
+	}
+	val, err := strconv.Atoi(r.scanner.Text())
+	if err != nil {
+		return 0, fmt.Errorf("bad input value %q", r.scanner.Text())
+	}
+	return val, nil
+}
+
+// writerOutput prints each value on its own line
+type writerOutput struct {
+	w io.Writer
+}
+
+func (w writerOutput) Write(val int) error {
+	_, err := fmt.Fprintln(w.w, val)
+	return err
+}
+
+// inputRecorder keeps a copy of every value read through it
+type inputRecorder struct {
+	in     Input
+	values []int
+}
+
+func (r *inputRecorder) Read() (int, error) {
+	val, err := r.in.Read()
+	if err == nil {
+		r.values = append(r.values, val)
+	}
+	return val, err
+}
+
+// outputRecorder keeps a copy of every value written through it, passing them
+// on to out unless out is nil
+type outputRecorder struct {
+	out    Output
+	values []int
+}
+
+func (r *outputRecorder) Write(val int) error {
+	r.values = append(r.values, val)
+	if r.out == nil {
+		return nil
+	}
+	return r.out.Write(val)
+}
+
+// runChannels runs m between two channels in the style of
+// executeProgramChannel.  The sender closes: output is closed on return.
+func (m *machine) runChannels(input <-chan int, output chan<- int) error {
+	defer close(output)
+	return m.run(channelInput(input), channelOutput(output))
+}
@@ -0,0 +1,539 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math/big"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const exitError = 1
+
+func isSeparator(b byte) bool {
+	return b == ',' || b == ' ' || b == '\t' || b == '\n' || b == '\r'
+}
+
+// inputSplit separates values on commas and whitespace, skipping empty tokens
+func inputSplit(data []byte, atEOF bool) (advance int, token []byte, err error) {
+	start := 0
+	for start < len(data) && isSeparator(data[start]) {
+		start++
+	}
+	for i := start; i < len(data); i++ {
+		if isSeparator(data[i]) {
+			return i + 1, data[start:i], nil
+		}
+	}
+	if atEOF && start < len(data) {
+		return len(data), data[start:], nil
+	}
+	return start, nil, nil
+}
+
+// scanInputs passes each value token read from r to send
+func scanInputs(r io.Reader, send func(token string)) {
+	scanner := bufio.NewScanner(r)
+	scanner.Split(inputSplit)
+	for scanner.Scan() {
+		send(scanner.Text())
+	}
+
+	if err := scanner.Err(); err != nil {
+		log.Fatalf("Error reading input: %v", err)
+	}
+}
+
+// readBigInputs lazily sends each arbitrary precision value read from r,
+// closing input at EOF
+func readBigInputs(r io.Reader, input chan<- *big.Int) {
+
+	defer close(input)
+
+	scanInputs(r, func(token string) {
+		val, ok := new(big.Int).SetString(token, 10)
+		if !ok {
+			log.Fatalf("Bad input value %q", token)
+		}
+		input <- val
+	})
+}
+
+// runCommand streams stdin to the program and its outputs to stdout
+func runCommand(args []string) {
+
+	flags := flag.NewFlagSet("run", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "usage: intcode run program.txt < input\n")
+		flags.PrintDefaults()
+	}
+	var limits resources
+	flags.IntVar(&limits.steps, "max-steps", 0, "stop after this many instructions (0 for no limit)")
+	flags.IntVar(&limits.memory, "max-memory", 0, "stop after touching this many memory cells (0 for no limit)")
+	flags.IntVar(&limits.outputs, "max-outputs", 0, "stop after this many outputs (0 for no limit)")
+	stats := flags.Bool("stats", false, "report resources used to stderr")
+	dump := flags.String("dump", "", "write memory to this file after the run, for intcode diff")
+	var devices deviceList
+	flags.Var(&devices, "device", "map a device into memory as kind@addr[:arg], kind is clock, random, screen or keyboard (repeatable)")
+	arithmetic := flags.String("arith", "wrap", "integer arithmetic: wrap, checked (fail on overflow) or big (arbitrary precision)")
+	onEmpty := flags.String("on-empty", "fault", "when stdin runs out: fault, pause (stop cleanly at the INP) or default (read -default)")
+	fallback := flags.Int("default", -1, "value read once stdin runs out with -on-empty default")
+	flags.Parse(args)
+	if flags.NArg() < 1 {
+		flags.Usage()
+		os.Exit(exitError)
+	}
+	policy, ok := policyNames[*onEmpty]
+	if !ok || policy == blockOnEmpty {
+		log.Fatalf("Unknown -on-empty policy %q", *onEmpty)
+	}
+
+	var usage *resources
+	var screens []*framebuffer
+	var final []string // memory once the machine stops
+	var runErr error
+	switch *arithmetic {
+	case "wrap", "checked":
+		program, err := loadProgram(flags.Arg(0))
+		if err != nil {
+			log.Fatal(err)
+		}
+		m := newMachine(program)
+		m.limits = limits
+		m.checked = *arithmetic == "checked"
+		m.onEmpty, m.fallback = policy, *fallback
+		usage = &m.usage
+		for _, spec := range devices {
+			screen, err := attachDevice(m, spec)
+			if err != nil {
+				log.Fatal(err)
+			}
+			if screen != nil {
+				screens = append(screens, screen)
+			}
+		}
+
+		runErr = m.run(newReaderInput(os.Stdin), writerOutput{os.Stdout})
+		if m.state == stateWaiting {
+			log.Printf("paused waiting for input at %d", m.ip)
+		}
+		for _, v := range m.memory {
+			final = append(final, strconv.Itoa(v))
+		}
+	case "big":
+		if len(devices) > 0 || policy != faultOnEmpty {
+			log.Fatalf("Devices and -on-empty need wrap or checked arithmetic")
+		}
+		program, err := loadBigProgram(flags.Arg(0))
+		if err != nil {
+			log.Fatal(err)
+		}
+		m := newBigMachine(program)
+		m.limits = limits
+		usage = &m.usage
+
+		input := make(chan *big.Int)
+		output := make(chan *big.Int)
+		done := make(chan error)
+		go readBigInputs(os.Stdin, input)
+		go func() {
+			done <- m.run(input, output)
+		}()
+		for v := range output {
+			fmt.Println(v)
+		}
+		runErr = <-done
+		for _, v := range m.memory {
+			if v == nil {
+				v = bigZero
+			}
+			final = append(final, v.String())
+		}
+	default:
+		log.Fatalf("Unknown arithmetic %s", *arithmetic)
+	}
+
+	if *dump != "" {
+		if err := writeDump(*dump, final); err != nil {
+			log.Fatal(err)
+		}
+	}
+	for _, screen := range screens {
+		screen.render(os.Stdout)
+	}
+	if *stats || runErr != nil {
+		log.Printf("used %v", *usage)
+	}
+	if runErr != nil {
+		log.Fatal(runErr)
+	}
+}
+
+// parseInts reads a comma separated list of integers
+func parseInts(text string) ([]int, error) {
+	vals := make([]int, 0)
+	for _, field := range strings.Split(text, ",") {
+		if strings.TrimSpace(field) == "" {
+			continue
+		}
+		val, err := strconv.Atoi(strings.TrimSpace(field))
+		if err != nil {
+			return nil, fmt.Errorf("bad value %q", field)
+		}
+		vals = append(vals, val)
+	}
+	return vals, nil
+}
+
+// solveCommand searches free memory cells for values meeting a goal
+func solveCommand(args []string) {
+
+	flags := flag.NewFlagSet("solve", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "usage: intcode solve -var addr:min:max [-var ...] (-target addr=value | -output value) program.txt\n")
+		flags.PrintDefaults()
+	}
+	var s search
+	flags.Var((*variableList)(&s.vars), "var", "free memory cell as addr:min:max (repeatable)")
+	target := flags.String("target", "", "goal as addr=value, checked in memory after halting")
+	lastOutput := flags.String("output", "", "goal value for the last output")
+	inputs := flags.String("input", "", "comma separated inputs for every run")
+	strategyName := flags.String("strategy", "early", "exhaustive, early, parallel or linear (a heuristic: assumes the target is linear after spot checks, falling back to exhaustive when a check fails)")
+	flags.IntVar(&s.limits.steps, "max-steps", 100000, "stop each run after this many instructions (0 for no limit)")
+	flags.Parse(args)
+	if flags.NArg() < 1 || len(s.vars) == 0 || (*target == "") == (*lastOutput == "") {
+		flags.Usage()
+		os.Exit(exitError)
+	}
+
+	var ok bool
+	if s.strategy, ok = strategyNames[*strategyName]; !ok {
+		log.Fatalf("Unknown strategy %s", *strategyName)
+	}
+
+	var err error
+	if *target != "" {
+		var vals []int
+		vals, err = parseInts(strings.Replace(*target, "=", ",", 1))
+		if err != nil || len(vals) != 2 {
+			log.Fatalf("Target %q is not addr=value", *target)
+		}
+		s.goal = objective{addr: vals[0], target: vals[1]}
+	} else {
+		want, err := strconv.Atoi(*lastOutput)
+		if err != nil {
+			log.Fatalf("Bad output value %q", *lastOutput)
+		}
+		s.goal.output = func(outputs []int) bool {
+			return len(outputs) > 0 && outputs[len(outputs)-1] == want
+		}
+	}
+	if s.inputs, err = parseInts(*inputs); err != nil {
+		log.Fatal(err)
+	}
+	if s.program, err = loadProgram(flags.Arg(0)); err != nil {
+		log.Fatal(err)
+	}
+
+	solutions, err := s.solve()
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, values := range solutions {
+		parts := make([]string, len(values))
+		for i, v := range values {
+			parts[i] = fmt.Sprintf("%d=%d", s.vars[i].addr, v)
+		}
+		fmt.Println(strings.Join(parts, " "))
+	}
+	if len(solutions) == 0 {
+		os.Exit(exitError)
+	}
+}
+
+// symbolicCommand runs a program with symbolic memory cells and prints the
+// resulting expressions, optionally solving for a target value
+func symbolicCommand(args []string) {
+
+	flags := flag.NewFlagSet("symbolic", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "usage: intcode symbolic -sym [name=]addr:min:max [-sym ...] [-target addr=value] program.txt\n")
+		flags.PrintDefaults()
+	}
+	r := symbolicRun{symbols: make(map[string]variable)}
+	flags.Var(symbolList(r.symbols), "sym", "symbolic memory cell as [name=]addr:min:max (repeatable)")
+	show := flags.Int("show", 0, "memory cell to print for each path")
+	target := flags.String("target", "", "solve for memory as addr=value")
+	inputs := flags.String("input", "", "comma separated inputs")
+	flags.IntVar(&r.maxPaths, "max-paths", 256, "give up after forking this many paths")
+	flags.IntVar(&r.maxSteps, "max-steps", 100000, "stop each path after this many instructions (0 for no limit)")
+	flags.Parse(args)
+	if flags.NArg() < 1 || len(r.symbols) == 0 {
+		flags.Usage()
+		os.Exit(exitError)
+	}
+
+	var err error
+	if r.inputs, err = parseInts(*inputs); err != nil {
+		log.Fatal(err)
+	}
+	if r.program, err = loadProgram(flags.Arg(0)); err != nil {
+		log.Fatal(err)
+	}
+	if err := r.explore(); err != nil {
+		log.Fatal(err)
+	}
+
+	for i, s := range r.halted {
+		fmt.Printf("path %d:\n", i)
+		if len(s.fixed) > 0 {
+			fmt.Printf("  with %s\n", formatAssignment(s.fixed))
+		}
+		for _, c := range s.constraints {
+			fmt.Printf("  where %v\n", c)
+		}
+		cell, _ := s.read(*show)
+		fmt.Printf("  mem[%d] = %v\n", *show, cell)
+		for _, o := range s.outputs {
+			fmt.Printf("  output %v\n", o)
+		}
+	}
+	for _, s := range r.failed {
+		log.Printf("path failed: %v", s.err)
+	}
+
+	if *target == "" {
+		return
+	}
+	vals, err := parseInts(strings.Replace(*target, "=", ",", 1))
+	if err != nil || len(vals) != 2 {
+		log.Fatalf("Target %q is not addr=value", *target)
+	}
+	solutions, err := r.solve(vals[0], vals[1])
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, solution := range solutions {
+		fmt.Printf("solution: %s\n", formatAssignment(solution))
+	}
+	if len(solutions) == 0 {
+		os.Exit(exitError)
+	}
+}
+
+// drawCommand renders the (x, y, tile) triplets a program outputs
+func drawCommand(args []string) {
+
+	flags := flag.NewFlagSet("draw", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "usage: intcode draw [-png image.png] program.txt < input\n")
+		flags.PrintDefaults()
+	}
+	glyphs := flags.String("glyphs", " #*=o", "character drawn for each tile value")
+	pngFile := flags.String("png", "", "write the screen to this PNG file instead of the terminal")
+	scale := flags.Int("scale", 8, "pixels per tile in the PNG")
+	flags.Parse(args)
+	if flags.NArg() < 1 {
+		flags.Usage()
+		os.Exit(exitError)
+	}
+
+	program, err := loadProgram(flags.Arg(0))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	s := newScreen()
+	if err := newMachine(program).run(newReaderInput(os.Stdin), s); err != nil {
+		log.Fatal(err)
+	}
+	if err := s.flush(); err != nil {
+		log.Fatal(err)
+	}
+
+	if *pngFile == "" {
+		s.render(os.Stdout, *glyphs)
+		return
+	}
+	file, err := os.Create(*pngFile)
+	if err != nil {
+		log.Fatalf("Error creating file %s", *pngFile)
+	}
+	defer file.Close()
+	if err := s.writePNG(file, *scale); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// paintCommand runs a hull painting robot and renders the hull
+func paintCommand(args []string) {
+
+	flags := flag.NewFlagSet("paint", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "usage: intcode paint [-start colour] program.txt\n")
+		flags.PrintDefaults()
+	}
+	start := flags.Int("start", 0, "colour of the starting panel")
+	glyphs := flags.String("glyphs", " #", "character drawn for each colour")
+	flags.Parse(args)
+	if flags.NArg() < 1 {
+		flags.Usage()
+		os.Exit(exitError)
+	}
+
+	program, err := loadProgram(flags.Arg(0))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	r := newRobot(*start)
+	if err := r.run(newMachine(program)); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("Painted: %d\n", len(r.painted))
+	r.hull.render(os.Stdout, *glyphs)
+}
+
+// exploreCommand maps a droid's maze and measures it from the target
+func exploreCommand(args []string) {
+
+	flags := flag.NewFlagSet("explore", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "usage: intcode explore program.txt\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() < 1 {
+		flags.Usage()
+		os.Exit(exitError)
+	}
+
+	program, err := loadProgram(flags.Arg(0))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	d, err := exploreMaze(newMachine(program))
+	if err != nil {
+		log.Fatal(err)
+	}
+	d.area.render(os.Stdout, mazeGlyphs)
+	if !d.found {
+		log.Fatalf("No target found")
+	}
+	fmt.Printf("Shortest path: %d\n", distances(d.area, point{})[d.target])
+	fmt.Printf("Fill time: %d\n", fillTime(d.area, d.target))
+}
+
+// replCommand starts an interactive shell, optionally running a script first
+func replCommand(args []string) {
+
+	flags := flag.NewFlagSet("repl", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "usage: intcode repl [-script commands.txt] [program.txt]\n")
+		flags.PrintDefaults()
+	}
+	script := flags.String("script", "", "execute the commands in this file and exit")
+	flags.Parse(args)
+
+	r := &repl{out: os.Stdout}
+	if flags.NArg() > 0 {
+		if err := r.execute("load " + flags.Arg(0)); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if *script != "" {
+		if err := r.source(*script); err != nil && err != errQuit {
+			log.Fatal(err)
+		}
+		return
+	}
+	if err := r.loop(os.Stdin, "> ", false); err != nil && err != errQuit {
+		log.Fatal(err)
+	}
+}
+
+// diffCommand compares two program images or memory dumps
+func diffCommand(args []string) {
+
+	flags := flag.NewFlagSet("diff", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "usage: intcode diff before.txt after.txt\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() < 2 {
+		flags.Usage()
+		os.Exit(exitError)
+	}
+
+	before, err := loadProgram(flags.Arg(0))
+	if err != nil {
+		log.Fatal(err)
+	}
+	after, err := loadProgram(flags.Arg(1))
+	if err != nil {
+		log.Fatal(err)
+	}
+	if diffPrograms(os.Stdout, before, after) > 0 {
+		os.Exit(exitError)
+	}
+}
+
+// writeDump saves memory in the same comma separated format programs are loaded from
+func writeDump(filename string, cells []string) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return fmt.Errorf("error creating file %s: %v", filename, err)
+	}
+	defer file.Close()
+
+	_, err = fmt.Fprintln(file, strings.Join(cells, ","))
+	return err
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: intcode command [arguments]\n\n")
+	fmt.Fprintf(os.Stderr, "commands:\n")
+	fmt.Fprintf(os.Stderr, "  repl      interactive shell to load, patch, step and run programs\n")
+	fmt.Fprintf(os.Stderr, "  run       run a program, reading inputs from stdin and writing outputs to stdout\n")
+	fmt.Fprintf(os.Stderr, "  solve     search free memory cells for values that meet a goal\n")
+	fmt.Fprintf(os.Stderr, "  diff      compare two program images or memory dumps\n")
+	fmt.Fprintf(os.Stderr, "  draw      render the (x, y, tile) triplets a program outputs\n")
+	fmt.Fprintf(os.Stderr, "  explore   map a droid's maze and find the shortest path to its target\n")
+	fmt.Fprintf(os.Stderr, "  paint     run a hull painting robot and render the hull\n")
+	fmt.Fprintf(os.Stderr, "  symbolic  run with symbolic memory cells and solve for a target\n")
+	os.Exit(exitError)
+}
+
+func main() {
+
+	if len(os.Args) < 2 {
+		usage()
+	}
+
+	switch os.Args[1] {
+	case "repl":
+		replCommand(os.Args[2:])
+	case "run":
+		runCommand(os.Args[2:])
+	case "solve":
+		solveCommand(os.Args[2:])
+	case "diff":
+		diffCommand(os.Args[2:])
+	case "draw":
+		drawCommand(os.Args[2:])
+	case "explore":
+		exploreCommand(os.Args[2:])
+	case "paint":
+		paintCommand(os.Args[2:])
+	case "symbolic":
+		symbolicCommand(os.Args[2:])
+	default:
+		usage()
+	}
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+)
+
+// droid movement commands, in the order they are tried, and their reverses
+var commands = [...]int{1, 2, 3, 4} // north, south, west, east
+var offsets = map[int]point{1: {0, -1}, 2: {0, 1}, 3: {-1, 0}, 4: {1, 0}}
+var reverse = map[int]int{1: 2, 2: 1, 3: 4, 4: 3}
+
+// droid status replies
+const (
+	hitWall     = 0
+	moved       = 1
+	foundTarget = 2
+)
+
+// maze tiles, rendered with mazeGlyphs
+const (
+	unknownTile = iota
+	wallTile
+	openTile
+	targetTile
+)
+
+const mazeGlyphs = " #.O"
+
+// droid explores a maze by sending movement commands to an intcode program
+// and reading back whether it hit a wall, moved, or moved onto the target
+type droid struct {
+	input    chan<- int
+	output   <-chan int
+	position point
+	area     *screen
+	target   point
+	found    bool
+}
+
+func newDroid(input chan<- int, output <-chan int) *droid {
+	d := &droid{input: input, output: output, area: newScreen()}
+	d.area.tiles[d.position] = openTile
+	return d
+}
+
+// move sends one command, records what the droid found and returns its reply.
+// A program that stops instead of reading the command closes output.
+func (d *droid) move(command int) (int, error) {
+	select {
+	case d.input <- command:
+	case status, ok := <-d.output:
+		if !ok {
+			return 0, fmt.Errorf("droid stopped at %v", d.position)
+		}
+		return 0, fmt.Errorf("droid replied %d before reading a command at %v", status, d.position)
+	}
+	status, ok := <-d.output
+	if !ok {
+		return 0, fmt.Errorf("droid stopped at %v", d.position)
+	}
+	next := point{d.position.X + offsets[command].X, d.position.Y + offsets[command].Y}
+	switch status {
+	case hitWall:
+		d.area.tiles[next] = wallTile
+		return status, nil
+	case moved:
+		d.area.tiles[next] = openTile
+	case foundTarget:
+		d.area.tiles[next] = targetTile
+		d.target = next
+		d.found = true
+	default:
+		return 0, fmt.Errorf("bad status %d at %v", status, d.position)
+	}
+	d.position = next
+	return status, nil
+}
+
+// explore visits every reachable cell depth first, backtracking to where it started
+func (d *droid) explore() error {
+	for _, command := range commands {
+		next := point{d.position.X + offsets[command].X, d.position.Y + offsets[command].Y}
+		if _, seen := d.area.tiles[next]; seen {
+			continue
+		}
+		status, err := d.move(command)
+		if err != nil {
+			return err
+		}
+		if status == hitWall {
+			continue
+		}
+		if err := d.explore(); err != nil {
+			return err
+		}
+		if status, err := d.move(reverse[command]); err != nil {
+			return err
+		} else if status == hitWall {
+			return fmt.Errorf("couldn't backtrack to %v", d.position)
+		}
+	}
+	return nil
+}
+
+// distances returns the number of steps from start to every open cell, breadth first
+func distances(area *screen, start point) map[point]int {
+	dist := map[point]int{start: 0}
+	queue := []point{start}
+	for len(queue) > 0 {
+		p := queue[0]
+		queue = queue[1:]
+		for _, command := range commands {
+			next := point{p.X + offsets[command].X, p.Y + offsets[command].Y}
+			tile := area.tiles[next]
+			if _, seen := dist[next]; seen || (tile != openTile && tile != targetTile) {
+				continue
+			}
+			dist[next] = dist[p] + 1
+			queue = append(queue, next)
+		}
+	}
+	return dist
+}
+
+// fillTime is the number of steps for something spreading from start to fill every open cell
+func fillTime(area *screen, start point) int {
+	var max int
+	for _, v := range distances(area, start) {
+		if max < v {
+			max = v
+		}
+	}
+	return max
+}
+
+// exploreMaze maps the maze of a droid program
+func exploreMaze(m *machine) (*droid, error) {
+	input := make(chan int)
+	output := make(chan int)
+	done := make(chan error, 1)
+	go func() {
+		done <- m.runChannels(input, output)
+	}()
+
+	d := newDroid(input, output)
+	err := d.explore()
+
+	// the droid program never halts by itself, so stop it by closing its input
+	close(input)
+	for range output {
+	}
+	// a fault in the program explains more than the droid stopping
+	if haltErr := <-done; haltErr != nil && !errors.Is(haltErr, errInputExhausted) {
+		err = haltErr
+	}
+	return d, err
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// droids scripted in a few instructions
+func TestExploreMaze(t *testing.T) {
+	// reads a command and reports a wall, forever
+	walled := []int{3, 100, 104, 0, 1105, 1, 0}
+	d, err := exploreMaze(newMachine(walled))
+	if err != nil {
+		t.Fatalf("walled: %v", err)
+	}
+	if d.found || d.area.count(wallTile) != 4 || d.area.count(openTile) != 1 {
+		t.Errorf("walled: found %v with %d walls and %d open", d.found, d.area.count(wallTile), d.area.count(openTile))
+	}
+
+	cases := []struct {
+		name    string
+		program []int
+		err     string
+	}{
+		{"halts", []int{99}, "stopped"},
+		{"faults", []int{77}, "error token"},
+		{"halts after a move", []int{3, 100, 104, 1, 99}, "stopped"},
+		{"talks first", []int{104, 1, 99}, "before reading"},
+	}
+	for _, c := range cases {
+		_, err := exploreMaze(newMachine(c.program))
+		if err == nil || !strings.Contains(err.Error(), c.err) {
+			t.Errorf("%s: got %v, want an error mentioning %q", c.name, err, c.err)
+		}
+	}
+}
+
+// a corridor from S to the target O, with a dead end leading off S
+var corridor = []string{
+	"#######",
+	"#S..#O#",
+	"#.#.#.#",
+	"#.#...#",
+	"#######",
+}
+
+// mazeScreen builds the area a droid would map from a picture, positions
+// relative to S
+func mazeScreen(rows []string) (*screen, point) {
+	area := newScreen()
+	var start, target point
+	for y, row := range rows {
+		for x, c := range row {
+			p := point{x, y}
+			switch c {
+			case '#':
+				area.tiles[p] = wallTile
+			case 'O':
+				area.tiles[p] = targetTile
+				target = p
+			default:
+				area.tiles[p] = openTile
+				if c == 'S' {
+					start = p
+				}
+			}
+		}
+	}
+	moved := newScreen()
+	for p, tile := range area.tiles {
+		moved.tiles[point{p.X - start.X, p.Y - start.Y}] = tile
+	}
+	return moved, point{target.X - start.X, target.Y - start.Y}
+}
+
+func TestDistances(t *testing.T) {
+	area, target := mazeScreen(corridor)
+	dist := distances(area, point{})
+	if len(dist) != 11 || dist[target] != 8 {
+		t.Errorf("%d cells reached, target at %d steps, want 11 and 8", len(dist), dist[target])
+	}
+	if got := fillTime(area, target); got != 10 {
+		t.Errorf("fillTime %d, want 10", got)
+	}
+}
+
+// scriptedDroid answers commands from the picture instead of an intcode
+// program, closing output once input is closed
+func scriptedDroid(rows []string, input <-chan int, output chan<- int) {
+	defer close(output)
+	area, _ := mazeScreen(rows)
+	var position point
+	for command := range input {
+		next := point{position.X + offsets[command].X, position.Y + offsets[command].Y}
+		switch area.tiles[next] {
+		case wallTile:
+			output <- hitWall
+		case targetTile:
+			position = next
+			output <- foundTarget
+		default:
+			position = next
+			output <- moved
+		}
+	}
+}
+
+func TestExploreBacktracks(t *testing.T) {
+	input := make(chan int)
+	output := make(chan int)
+	go scriptedDroid(corridor, input, output)
+	d := newDroid(input, output)
+	err := d.explore()
+	close(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, target := mazeScreen(corridor)
+	if !d.found || d.target != target {
+		t.Errorf("found %v at %v, want %v", d.found, d.target, target)
+	}
+	if d.position != (point{}) {
+		t.Errorf("ended at %v instead of backtracking to the start", d.position)
+	}
+	for p, tile := range d.area.tiles {
+		if want.tiles[p] != tile {
+			t.Errorf("%v mapped as %d, want %d", p, tile, want.tiles[p])
+		}
+	}
+	if got := d.area.count(openTile) + d.area.count(targetTile); got != 11 {
+		t.Errorf("mapped %d open cells, want 11", got)
+	}
+}
@@ -0,0 +1,299 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// replSteps stops a run command that neither outputs, waits nor halts
+const replSteps = 1 << 20
+
+// maxPeek is the most cells one peek prints
+const maxPeek = 1 << 12
+
+const replHelp = `commands:
+  load FILE            load a program and reset the machine
+  reset                restore the loaded program, reapplying patches
+  peek ADDR [COUNT]    print memory
+  poke ADDR VAL...     write memory until the next reset
+  patch ADDR VAL...    write memory now and after every reset
+  patches              list patches
+  unpatch [N]          drop patch N, or every patch
+  input VAL...         queue input values
+  run                  run until an output, a read with no queued input, or a halt
+  step [N]             execute N instructions (default 1)
+  state                print the instruction pointer, relative base and usage
+  history              list previous commands
+  !N                   repeat command N from the history
+  source FILE          execute the commands in FILE
+  help                 print this message
+  quit                 leave
+`
+
+// patch is a memory write reapplied every time the machine is reset
+type patch struct {
+	addr int
+	vals []int
+}
+
+// repl is an interactive shell around a machine, for trying patches like day
+// 2's fixProgram without editing main
+type repl struct {
+	program []int
+	patches []patch
+	m       *machine
+	inputs  []int
+	history []string
+	out     io.Writer
+}
+
+// errQuit is returned by the quit command
+var errQuit = errors.New("quit")
+
+func (r *repl) reset() error {
+	if r.program == nil {
+		return errors.New("no program loaded")
+	}
+	r.m = newMachine(r.program)
+	r.m.onEmpty = pauseOnEmpty
+	for _, p := range r.patches {
+		if err := r.poke(p.addr, p.vals); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *repl) poke(addr int, vals []int) error {
+	for i, v := range vals {
+		if err := r.m.write(addr+i, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// single executes one instruction, feeding it the next queued input and
+// printing any output.  It reports whether the instruction output a value.
+func (r *repl) single() (bool, error) {
+	in := &sliceInput{r.inputs}
+	out := &outputRecorder{}
+	halted, err := r.m.step(in, out)
+	r.inputs = in.values
+	if err != nil {
+		return false, err
+	}
+	if halted {
+		fmt.Fprintf(r.out, "halted at %d\n", r.m.ip)
+	}
+	for _, v := range out.values {
+		fmt.Fprintf(r.out, "output %d\n", v)
+	}
+	return len(out.values) > 0, nil
+}
+
+// steps executes up to n instructions, stopping early on output when untilOutput is set
+func (r *repl) steps(n int, untilOutput bool) error {
+	for i := 0; i < n; i++ {
+		if r.m.state == stateHalted {
+			return errors.New("machine has halted, reset to run again")
+		}
+		output, err := r.single()
+		if err != nil {
+			return err
+		}
+		if r.m.state == stateWaiting {
+			fmt.Fprintf(r.out, "waiting for input at %d\n", r.m.ip)
+			return nil
+		}
+		if (output && untilOutput) || r.m.state == stateHalted {
+			return nil
+		}
+	}
+	if untilOutput {
+		return fmt.Errorf("still running after %d steps at %d", n, r.m.ip)
+	}
+	return nil
+}
+
+// intArgs parses the integer arguments of a command
+func intArgs(fields []string, min int) ([]int, error) {
+	if len(fields) < min {
+		return nil, fmt.Errorf("%s needs at least %d arguments", fields[0], min-1)
+	}
+	vals := make([]int, len(fields)-1)
+	for i, field := range fields[1:] {
+		val, err := strconv.Atoi(field)
+		if err != nil {
+			return nil, fmt.Errorf("bad number %q", field)
+		}
+		vals[i] = val
+	}
+	return vals, nil
+}
+
+// execute runs a single command line
+func (r *repl) execute(line string) error {
+	fields := strings.Fields(line)
+	if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+		return nil
+	}
+	if strings.HasPrefix(fields[0], "!") {
+		n, err := strconv.Atoi(fields[0][1:])
+		if err != nil || n < 0 || n >= len(r.history) {
+			return fmt.Errorf("no command %s in the history", fields[0])
+		}
+		fmt.Fprintf(r.out, "%s\n", r.history[n])
+		return r.execute(r.history[n])
+	}
+	r.history = append(r.history, line)
+
+	if r.m == nil && fields[0] != "load" && fields[0] != "source" && fields[0] != "help" &&
+		fields[0] != "history" && fields[0] != "quit" {
+		return errors.New("no program loaded")
+	}
+
+	switch fields[0] {
+	case "load":
+		if len(fields) != 2 {
+			return errors.New("load needs a file name")
+		}
+		program, err := loadProgram(fields[1])
+		if err != nil {
+			return err
+		}
+		r.program = program
+		r.patches = nil
+		r.inputs = nil
+		return r.reset()
+	case "reset":
+		r.inputs = nil
+		return r.reset()
+	case "peek":
+		vals, err := intArgs(fields, 2)
+		if err != nil {
+			return err
+		}
+		count := 1
+		if len(vals) > 1 {
+			count = vals[1]
+		}
+		if count < 1 || count > maxPeek {
+			return fmt.Errorf("peek count must be between 1 and %d", maxPeek)
+		}
+		if vals[0] < 0 {
+			return fmt.Errorf("peek at negative address %d", vals[0])
+		}
+		// straight from memory, so peeking doesn't count as the program
+		// touching cells
+		cells := make([]string, 0, count)
+		for addr := vals[0]; addr < vals[0]+count; addr++ {
+			v := 0
+			if addr < len(r.m.memory) {
+				v = r.m.memory[addr]
+			}
+			cells = append(cells, strconv.Itoa(v))
+		}
+		fmt.Fprintf(r.out, "%d: %s\n", vals[0], strings.Join(cells, ","))
+	case "poke":
+		vals, err := intArgs(fields, 3)
+		if err != nil {
+			return err
+		}
+		return r.poke(vals[0], vals[1:])
+	case "patch":
+		vals, err := intArgs(fields, 3)
+		if err != nil {
+			return err
+		}
+		r.patches = append(r.patches, patch{vals[0], vals[1:]})
+		return r.poke(vals[0], vals[1:])
+	case "patches":
+		for i, p := range r.patches {
+			fmt.Fprintf(r.out, "%d: %d <- %v\n", i, p.addr, p.vals)
+		}
+	case "unpatch":
+		vals, err := intArgs(fields, 1)
+		if err != nil {
+			return err
+		}
+		if len(vals) == 0 {
+			r.patches = nil
+		} else if vals[0] < 0 || vals[0] >= len(r.patches) {
+			return fmt.Errorf("no patch %d", vals[0])
+		} else {
+			r.patches = append(r.patches[:vals[0]], r.patches[vals[0]+1:]...)
+		}
+	case "input":
+		vals, err := intArgs(fields, 2)
+		if err != nil {
+			return err
+		}
+		r.inputs = append(r.inputs, vals...)
+	case "run":
+		return r.steps(replSteps, true)
+	case "step":
+		vals, err := intArgs(fields, 1)
+		if err != nil {
+			return err
+		}
+		n := 1
+		if len(vals) > 0 {
+			n = vals[0]
+		}
+		return r.steps(n, false)
+	case "state":
+		fmt.Fprintf(r.out, "ip=%d base=%d state=%v inputs=%v %v\n", r.m.ip, r.m.relativeBase, r.m.state, r.inputs, r.m.usage)
+	case "history":
+		for i, h := range r.history {
+			fmt.Fprintf(r.out, "%d  %s\n", i, h)
+		}
+	case "source":
+		if len(fields) != 2 {
+			return errors.New("source needs a file name")
+		}
+		return r.source(fields[1])
+	case "help":
+		fmt.Fprint(r.out, replHelp)
+	case "quit":
+		return errQuit
+	default:
+		return fmt.Errorf("unknown command %s, try help", fields[0])
+	}
+	return nil
+}
+
+// loop executes commands from in until it ends or quit.  Errors are reported
+// and the loop carries on unless stopOnError is set, as it is for scripts.
+func (r *repl) loop(in io.Reader, prompt string, stopOnError bool) error {
+	scanner := bufio.NewScanner(in)
+	fmt.Fprint(r.out, prompt)
+	for scanner.Scan() {
+		if err := r.execute(scanner.Text()); err == errQuit {
+			return err
+		} else if err != nil {
+			if stopOnError {
+				return fmt.Errorf("%s: %v", scanner.Text(), err)
+			}
+			fmt.Fprintf(r.out, "error: %v\n", err)
+		}
+		fmt.Fprint(r.out, prompt)
+	}
+	return scanner.Err()
+}
+
+// source executes a script of commands
+func (r *repl) source(filename string) error {
+	file, err := os.Open(filename)
+	if err != nil {
+		return fmt.Errorf("error opening file %s", filename)
+	}
+	defer file.Close()
+
+	return r.loop(file, "", true)
+}
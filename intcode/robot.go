@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+)
+
+// headings in clockwise order, starting facing up
+var headings = [...]point{{0, -1}, {1, 0}, {0, 1}, {-1, 0}}
+
+// robot is a hull painting robot driven by an intcode program.  The program
+// reads the colour of the panel under the robot and outputs (paint, turn)
+// pairs, turn 0 for left and 1 for right, after which the robot moves forward.
+type robot struct {
+	hull     *screen
+	position point
+	heading  int
+	painted  map[point]bool
+}
+
+func newRobot(startColour int) *robot {
+	r := &robot{hull: newScreen(), painted: make(map[point]bool)}
+	r.hull.tiles[r.position] = startColour
+	return r
+}
+
+// step paints the current panel, turns and moves forward
+func (r *robot) step(paint int, turn int) error {
+	switch turn {
+	case 0:
+		r.heading = (r.heading + len(headings) - 1) % len(headings)
+	case 1:
+		r.heading = (r.heading + 1) % len(headings)
+	default:
+		return fmt.Errorf("bad turn %d at %v", turn, r.position)
+	}
+	r.hull.tiles[r.position] = paint
+	r.painted[r.position] = true
+	r.position.X += headings[r.heading].X
+	r.position.Y += headings[r.heading].Y
+	return nil
+}
+
+// run drives the machine until it halts, answering every input with the
+// colour of the panel under the robot
+func (r *robot) run(m *machine) error {
+	camera := InputFunc(func() (int, error) {
+		return r.hull.tiles[r.position], nil
+	})
+	pending := make([]int, 0, 2)
+	motors := OutputFunc(func(v int) error {
+		pending = append(pending, v)
+		if len(pending) < 2 {
+			return nil
+		}
+		paint, turn := pending[0], pending[1]
+		pending = pending[:0]
+		return r.step(paint, turn)
+	})
+
+	if err := m.run(camera, motors); err != nil {
+		return err
+	}
+	if len(pending) > 0 {
+		return fmt.Errorf("program halted between paint and turn")
+	}
+	return nil
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+)
+
+type point struct {
+	X, Y int
+}
+
+// screen collects the tiles a drawing program outputs as (x, y, tile)
+// triplets.  A triplet at the score sentinel sets the score instead of a tile.
+type screen struct {
+	tiles    map[point]int
+	sentinel point
+	score    int
+	scored   bool
+	pending  []int
+}
+
+func newScreen() *screen {
+	return &screen{tiles: make(map[point]int), sentinel: point{-1, 0}}
+}
+
+// set handles one triplet
+func (s *screen) set(x, y, tile int) {
+	if (point{x, y}) == s.sentinel {
+		s.score = tile
+		s.scored = true
+		return
+	}
+	s.tiles[point{x, y}] = tile
+}
+
+// Write makes the screen an Output, collecting values into triplets
+func (s *screen) Write(v int) error {
+	s.pending = append(s.pending, v)
+	if len(s.pending) == 3 {
+		s.set(s.pending[0], s.pending[1], s.pending[2])
+		s.pending = s.pending[:0]
+	}
+	return nil
+}
+
+// flush reports output that ended partway through a triplet
+func (s *screen) flush() error {
+	if len(s.pending) > 0 {
+		return fmt.Errorf("output ended partway through a triplet %v", s.pending)
+	}
+	return nil
+}
+
+// count returns the number of tiles of the given kind
+func (s *screen) count(tile int) int {
+	var k int
+	for _, v := range s.tiles {
+		if v == tile {
+			k++
+		}
+	}
+	return k
+}
+
+// bounds returns the smallest and largest coordinates drawn
+func (s *screen) bounds() (min, max point) {
+	first := true
+	for p := range s.tiles {
+		if first {
+			min, max = p, p
+			first = false
+		}
+		if p.X < min.X {
+			min.X = p.X
+		}
+		if p.Y < min.Y {
+			min.Y = p.Y
+		}
+		if p.X > max.X {
+			max.X = p.X
+		}
+		if p.Y > max.Y {
+			max.Y = p.Y
+		}
+	}
+	return min, max
+}
+
+// render draws the tiles like day 8's renderImage, using glyphs[tile] for
+// each tile and '?' for tiles past the end of glyphs
+func (s *screen) render(w io.Writer, glyphs string) {
+	min, max := s.bounds()
+	for y := min.Y; y <= max.Y && len(s.tiles) > 0; y++ {
+		for x := min.X; x <= max.X; x++ {
+			tile := s.tiles[point{x, y}]
+			if tile >= 0 && tile < len(glyphs) {
+				fmt.Fprintf(w, "%c", glyphs[tile])
+			} else {
+				fmt.Fprintf(w, "?")
+			}
+		}
+		fmt.Fprintf(w, "\n")
+	}
+	if s.scored {
+		fmt.Fprintf(w, "Score: %d\n", s.score)
+	}
+}
+
+// palette colours tiles 0 to 4: empty, wall, block, paddle, ball
+var palette = color.Palette{
+	color.Black,
+	color.White,
+	color.RGBA{0x80, 0x80, 0x80, 0xff},
+	color.RGBA{0x20, 0x60, 0xff, 0xff},
+	color.RGBA{0xff, 0x40, 0x20, 0xff},
+	color.RGBA{0xff, 0x00, 0xff, 0xff}, // anything else
+}
+
+// writePNG encodes the tiles as an image with each tile scale pixels square
+func (s *screen) writePNG(w io.Writer, scale int) error {
+	min, max := s.bounds()
+	width, height := max.X-min.X+1, max.Y-min.Y+1
+	if len(s.tiles) == 0 {
+		width, height = 1, 1
+	}
+	img := image.NewPaletted(image.Rect(0, 0, width*scale, height*scale), palette)
+	for p, tile := range s.tiles {
+		index := uint8(len(palette) - 1)
+		if tile >= 0 && tile < len(palette)-1 {
+			index = uint8(tile)
+		}
+		for dy := 0; dy < scale; dy++ {
+			for dx := 0; dx < scale; dx++ {
+				img.SetColorIndex((p.X-min.X)*scale+dx, (p.Y-min.Y)*scale+dy, index)
+			}
+		}
+	}
+	return png.Encode(w, img)
+}
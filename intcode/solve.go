@@ -0,0 +1,331 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// variable is a memory cell left free during a search, taking values in [min, max]
+type variable struct {
+	addr     int
+	min, max int
+}
+
+func (v variable) size() int {
+	return v.max - v.min + 1
+}
+
+// objective decides whether a halted run is a solution.  When output is set it
+// is checked against the run's outputs, otherwise memory[addr] must equal target.
+type objective struct {
+	addr   int
+	target int
+	output func([]int) bool
+}
+
+func (o objective) met(m *machine, outputs []int) bool {
+	if o.output != nil {
+		return o.output(outputs)
+	}
+	val, err := m.read(o.addr)
+	return err == nil && val == o.target
+}
+
+type strategy int
+
+const (
+	exhaustive strategy = iota // try every assignment in order
+	earlyExit                  // stop at the first solution
+	parallel                   // try every assignment across all CPUs
+	linear                     // solve analytically when probing suggests the target is linear
+)
+
+var strategyNames = map[string]strategy{
+	"exhaustive": exhaustive,
+	"early":      earlyExit,
+	"parallel":   parallel,
+	"linear":     linear,
+}
+
+// search describes a parameter search over a program, generalizing day 2's noun/verb grid
+type search struct {
+	program  []int
+	inputs   []int
+	vars     []variable
+	goal     objective
+	limits   resources
+	strategy strategy
+}
+
+// evaluate runs the program with the variables set to values.  Runs that fail
+// (bad opcodes, budgets) are reported as errors and never count as solutions.
+func (s search) evaluate(values []int) (*machine, []int, error) {
+	m := newMachine(s.program)
+	m.limits = s.limits
+	for i, v := range s.vars {
+		if err := m.write(v.addr, values[i]); err != nil {
+			return nil, nil, err
+		}
+	}
+	outputs, err := m.runSlice(s.inputs)
+	return m, outputs, err
+}
+
+func (s search) solves(values []int) bool {
+	m, outputs, err := s.evaluate(values)
+	return err == nil && s.goal.met(m, outputs)
+}
+
+// count is the number of assignments in the search space
+func (s search) count() int {
+	total := 1
+	for _, v := range s.vars {
+		total *= v.size()
+	}
+	return total
+}
+
+// assignment converts k into the kth assignment, with the last variable varying fastest
+func (s search) assignment(k int) []int {
+	values := make([]int, len(s.vars))
+	for i := len(s.vars) - 1; i >= 0; i-- {
+		values[i] = s.vars[i].min + k%s.vars[i].size()
+		k /= s.vars[i].size()
+	}
+	return values
+}
+
+// solve returns the assignments, in search order, that meet the goal
+func (s search) solve() ([][]int, error) {
+	for _, v := range s.vars {
+		if v.size() < 1 {
+			return nil, fmt.Errorf("empty range %d:%d for address %d", v.min, v.max, v.addr)
+		}
+	}
+
+	switch s.strategy {
+	case parallel:
+		return s.solveParallel(), nil
+	case linear:
+		return s.solveLinear()
+	}
+
+	solutions := make([][]int, 0)
+	for k := 0; k < s.count(); k++ {
+		values := s.assignment(k)
+		if s.solves(values) {
+			solutions = append(solutions, values)
+			if s.strategy == earlyExit {
+				break
+			}
+		}
+	}
+	return solutions, nil
+}
+
+func (s search) solveParallel() [][]int {
+	var wg sync.WaitGroup
+	var lock sync.Mutex
+	found := make([]int, 0)
+
+	work := make(chan int)
+	for w := 0; w < runtime.NumCPU(); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for k := range work {
+				if s.solves(s.assignment(k)) {
+					lock.Lock()
+					found = append(found, k)
+					lock.Unlock()
+				}
+			}
+		}()
+	}
+	for k := 0; k < s.count(); k++ {
+		work <- k
+	}
+	close(work)
+	wg.Wait()
+
+	sort.Ints(found)
+	solutions := make([][]int, len(found))
+	for i, k := range found {
+		solutions[i] = s.assignment(k)
+	}
+	return solutions
+}
+
+// targetValue runs the program and reads the goal address
+func (s search) targetValue(values []int) (int, error) {
+	m, _, err := s.evaluate(values)
+	if err != nil {
+		return 0, err
+	}
+	return m.read(s.goal.addr)
+}
+
+// solveLinear fits target = base + sum(coef[i] * (x[i] - min[i])) by probing each
+// variable once, checks the fit at the far corner and the midpoint, then solves
+// for the variable with the widest range while enumerating the others.  Every
+// enumerated slice is checked at both ends of that range.  A probe or check
+// that faults or doesn't fit falls back to exhaustive search.  It is still a heuristic: a target that is
+// nonlinear only strictly inside the solved range can lose solutions.
+func (s search) solveLinear() ([][]int, error) {
+	if s.goal.output != nil {
+		return nil, errors.New("linear strategy needs a memory target")
+	}
+	// probes that fault or don't fit mean trying every assignment instead
+	fallback := func() ([][]int, error) {
+		s.strategy = exhaustive
+		return s.solve()
+	}
+
+	mins := make([]int, len(s.vars))
+	for i, v := range s.vars {
+		mins[i] = v.min
+	}
+	base, err := s.targetValue(mins)
+	if err != nil {
+		return fallback()
+	}
+
+	coef := make([]int, len(s.vars))
+	for i, v := range s.vars {
+		if v.size() == 1 {
+			continue
+		}
+		probe := append([]int{}, mins...)
+		probe[i]++
+		val, err := s.targetValue(probe)
+		if err != nil {
+			return fallback()
+		}
+		coef[i] = val - base
+	}
+
+	predict := func(values []int) int {
+		val := base
+		for i, v := range s.vars {
+			val += coef[i] * (values[i] - v.min)
+		}
+		return val
+	}
+	maxes := make([]int, len(s.vars))
+	mids := make([]int, len(s.vars))
+	for i, v := range s.vars {
+		maxes[i] = v.max
+		mids[i] = v.min + (v.max-v.min)/2
+	}
+	for _, check := range [][]int{maxes, mids} {
+		val, err := s.targetValue(check)
+		if err != nil {
+			return fallback()
+		}
+		if val != predict(check) {
+			return fallback()
+		}
+	}
+
+	// pick the variable to solve for
+	solveFor := -1
+	for i, v := range s.vars {
+		if coef[i] != 0 && (solveFor < 0 || v.size() > s.vars[solveFor].size()) {
+			solveFor = i
+		}
+	}
+	if solveFor < 0 {
+		if base != s.goal.target {
+			return [][]int{}, nil
+		}
+		return fallback()
+	}
+
+	rest := search{vars: append(append([]variable{}, s.vars[:solveFor]...), s.vars[solveFor+1:]...)}
+	solutions := make([][]int, 0)
+	for k := 0; k < rest.count(); k++ {
+		partial := rest.assignment(k)
+		values := make([]int, 0, len(s.vars))
+		values = append(values, partial[:solveFor]...)
+		values = append(values, s.vars[solveFor].min)
+		values = append(values, partial[solveFor:]...)
+
+		// confirm the fit at both ends of this slice, falling back to trying
+		// everything if it doesn't hold, rather than missing solutions
+		for _, end := range []int{s.vars[solveFor].min, s.vars[solveFor].max} {
+			values[solveFor] = end
+			val, err := s.targetValue(values)
+			if err != nil {
+				return fallback()
+			}
+			if val != predict(values) {
+				return fallback()
+			}
+		}
+		values[solveFor] = s.vars[solveFor].min
+
+		remainder := s.goal.target - predict(values)
+		if remainder%coef[solveFor] != 0 {
+			continue
+		}
+		values[solveFor] += remainder / coef[solveFor]
+		if values[solveFor] > s.vars[solveFor].max || values[solveFor] < s.vars[solveFor].min {
+			continue
+		}
+		// the probes can't rule out every nonlinearity, so confirm concretely
+		if s.solves(values) {
+			solutions = append(solutions, values)
+		}
+	}
+	sort.Slice(solutions, func(i, j int) bool {
+		for k := range solutions[i] {
+			if solutions[i][k] != solutions[j][k] {
+				return solutions[i][k] < solutions[j][k]
+			}
+		}
+		return false
+	})
+	return solutions, nil
+}
+
+// parseVariable reads addr:min:max
+func parseVariable(text string) (variable, error) {
+	parts := strings.Split(text, ":")
+	if len(parts) != 3 {
+		return variable{}, fmt.Errorf("variable %q is not addr:min:max", text)
+	}
+	vals := make([]int, 3)
+	for i, part := range parts {
+		val, err := strconv.Atoi(part)
+		if err != nil {
+			return variable{}, fmt.Errorf("variable %q is not addr:min:max", text)
+		}
+		vals[i] = val
+	}
+	return variable{addr: vals[0], min: vals[1], max: vals[2]}, nil
+}
+
+// variableList collects repeated -var flags
+type variableList []variable
+
+func (l *variableList) String() string {
+	parts := make([]string, len(*l))
+	for i, v := range *l {
+		parts[i] = fmt.Sprintf("%d:%d:%d", v.addr, v.min, v.max)
+	}
+	return strings.Join(parts, ",")
+}
+
+func (l *variableList) Set(text string) error {
+	v, err := parseVariable(text)
+	if err != nil {
+		return err
+	}
+	*l = append(*l, v)
+	return nil
+}
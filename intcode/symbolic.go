@@ -0,0 +1,826 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// poly is a polynomial with integer coefficients over named symbols.  Each key
+// is a monomial, its symbols sorted and joined by '*', and "" is the constant term.
+// Zero coefficients are never stored and polys are never modified once built.
+type poly map[string]int
+
+func constant(c int) poly {
+	if c == 0 {
+		return poly{}
+	}
+	return poly{"": c}
+}
+
+func symbol(name string) poly {
+	return poly{name: 1}
+}
+
+// value returns the constant value of p, if it has no symbols
+func (p poly) value() (int, bool) {
+	switch len(p) {
+	case 0:
+		return 0, true
+	case 1:
+		c, ok := p[""]
+		return c, ok
+	}
+	return 0, false
+}
+
+func factors(monomial string) []string {
+	if monomial == "" {
+		return nil
+	}
+	return strings.Split(monomial, "*")
+}
+
+func (p poly) add(q poly) poly {
+	sum := make(poly, len(p)+len(q))
+	for k, c := range p {
+		sum[k] = c
+	}
+	for k, c := range q {
+		if sum[k]+c == 0 {
+			delete(sum, k)
+		} else {
+			sum[k] += c
+		}
+	}
+	return sum
+}
+
+func (p poly) sub(q poly) poly {
+	return p.add(q.mul(constant(-1)))
+}
+
+func (p poly) mul(q poly) poly {
+	product := make(poly)
+	for k1, c1 := range p {
+		for k2, c2 := range q {
+			names := append(factors(k1), factors(k2)...)
+			sort.Strings(names)
+			k := strings.Join(names, "*")
+			if product[k]+c1*c2 == 0 {
+				delete(product, k)
+			} else {
+				product[k] += c1 * c2
+			}
+		}
+	}
+	return product
+}
+
+// linear reports whether every monomial has at most one symbol
+func (p poly) linear() bool {
+	for k := range p {
+		if len(factors(k)) > 1 {
+			return false
+		}
+	}
+	return true
+}
+
+// symbols lists the distinct symbols in p, sorted
+func (p poly) symbols() []string {
+	seen := make(map[string]bool)
+	names := make([]string, 0)
+	for k := range p {
+		for _, name := range factors(k) {
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// substitute replaces every symbol found in vals by its value
+func (p poly) substitute(vals map[string]int) poly {
+	result := make(poly)
+	for k, c := range p {
+		rest := make([]string, 0)
+		for _, name := range factors(k) {
+			if v, ok := vals[name]; ok {
+				c *= v
+			} else {
+				rest = append(rest, name)
+			}
+		}
+		result = result.add(poly{strings.Join(rest, "*"): c})
+	}
+	return result
+}
+
+func (p poly) String() string {
+	if len(p) == 0 {
+		return "0"
+	}
+	keys := make([]string, 0, len(p))
+	for k := range p {
+		keys = append(keys, k)
+	}
+	// highest degree first, constant last
+	sort.Slice(keys, func(i, j int) bool {
+		di, dj := len(factors(keys[i])), len(factors(keys[j]))
+		if di != dj {
+			return di > dj
+		}
+		return keys[i] < keys[j]
+	})
+	var b strings.Builder
+	for i, k := range keys {
+		c := p[k]
+		switch {
+		case i > 0 && c < 0:
+			b.WriteString(" - ")
+			c = -c
+		case i > 0:
+			b.WriteString(" + ")
+		case c < 0:
+			b.WriteString("-")
+			c = -c
+		}
+		switch {
+		case k == "":
+			fmt.Fprintf(&b, "%d", c)
+		case c == 1:
+			b.WriteString(k)
+		default:
+			fmt.Fprintf(&b, "%d*%s", c, k)
+		}
+	}
+	return b.String()
+}
+
+// relation is how a constraint's poly compares with zero
+type relation int
+
+const (
+	equalZero relation = iota
+	notZero
+	lessZero
+	notLessZero
+)
+
+var relationNames = [...]string{"== 0", "!= 0", "< 0", ">= 0"}
+
+func (r relation) holds(v int) bool {
+	switch r {
+	case equalZero:
+		return v == 0
+	case notZero:
+		return v != 0
+	case lessZero:
+		return v < 0
+	}
+	return v >= 0
+}
+
+// constraint records a branch decision taken on a symbolic value
+type constraint struct {
+	p   poly
+	rel relation
+}
+
+func (c constraint) String() string {
+	return fmt.Sprintf("%v %s", c.p, relationNames[c.rel])
+}
+
+// atom stands for a read through a symbolic address, remembering the memory it was read from
+type atom struct {
+	addr   poly
+	memory []poly
+}
+
+// symbolicPath is one path through a program run with symbolic memory cells
+type symbolicPath struct {
+	memory       []poly
+	ip           int
+	relativeBase int
+	inputs       []int
+	outputs      []poly
+	steps        int
+
+	constraints []constraint
+	fixed       map[string]int // symbols concretized on this path
+	atoms       map[string]atom
+	err         error
+}
+
+func (s *symbolicPath) fork() *symbolicPath {
+	f := *s
+	f.memory = append([]poly{}, s.memory...)
+	f.outputs = append([]poly{}, s.outputs...)
+	f.constraints = append([]constraint{}, s.constraints...)
+	f.fixed = make(map[string]int)
+	for k, v := range s.fixed {
+		f.fixed[k] = v
+	}
+	f.atoms = make(map[string]atom)
+	for k, v := range s.atoms {
+		f.atoms[k] = v
+	}
+	return &f
+}
+
+func (s *symbolicPath) read(addr int) (poly, error) {
+	if addr < 0 {
+		return nil, fmt.Errorf("read from negative address %d at %d", addr, s.ip)
+	}
+	if addr >= len(s.memory) {
+		return constant(0), nil
+	}
+	return s.memory[addr], nil
+}
+
+func (s *symbolicPath) write(addr int, val poly) error {
+	if addr < 0 {
+		return fmt.Errorf("write to negative address %d at %d", addr, s.ip)
+	}
+	if addr >= len(s.memory) {
+		grown := make([]poly, addr+1)
+		copy(grown, s.memory)
+		for i := len(s.memory); i < len(grown); i++ {
+			grown[i] = constant(0)
+		}
+		s.memory = grown
+	}
+	s.memory[addr] = val
+	return nil
+}
+
+// resolve substitutes vals into p and replaces any atom whose address becomes
+// concrete by the value it read
+func (s *symbolicPath) resolve(p poly, vals map[string]int) poly {
+	p = p.substitute(vals)
+	for {
+		found := make(map[string]int)
+		for _, name := range p.symbols() {
+			a, ok := s.atoms[name]
+			if !ok {
+				continue
+			}
+			addr, ok := s.resolve(a.addr, vals).value()
+			if !ok {
+				continue
+			}
+			var cell poly = constant(0)
+			if addr >= 0 && addr < len(a.memory) {
+				cell = a.memory[addr]
+			}
+			if v, ok := s.resolve(cell, vals).value(); ok {
+				found[name] = v
+			}
+		}
+		if len(found) == 0 {
+			return p
+		}
+		p = p.substitute(found)
+	}
+}
+
+// symbolicRun explores every path of a program with some memory cells left symbolic
+type symbolicRun struct {
+	program  []int
+	inputs   []int
+	symbols  map[string]variable
+	maxPaths int
+	maxSteps int
+
+	halted []*symbolicPath
+	failed []*symbolicPath
+}
+
+// bounds returns the range of a linear p over the symbol ranges
+func (r *symbolicRun) bounds(p poly) (lo, hi int, ok bool) {
+	if !p.linear() {
+		return 0, 0, false
+	}
+	for k, c := range p {
+		if k == "" {
+			lo += c
+			hi += c
+			continue
+		}
+		v, ok := r.symbols[k]
+		if !ok {
+			return 0, 0, false
+		}
+		if c > 0 {
+			lo += c * v.min
+			hi += c * v.max
+		} else {
+			lo += c * v.max
+			hi += c * v.min
+		}
+	}
+	return lo, hi, true
+}
+
+// possible reports whether rel can hold for p, and whether it must
+func (r *symbolicRun) possible(p poly, rel relation) (can, must bool) {
+	if v, ok := p.value(); ok {
+		return rel.holds(v), rel.holds(v)
+	}
+	lo, hi, ok := r.bounds(p)
+	if !ok {
+		return true, false
+	}
+	switch rel {
+	case equalZero:
+		return lo <= 0 && hi >= 0, lo == 0 && hi == 0
+	case notZero:
+		return lo != 0 || hi != 0, lo > 0 || hi < 0
+	case lessZero:
+		return lo < 0, hi < 0
+	}
+	return hi >= 0, lo >= 0
+}
+
+// branch splits s on whether rel holds for p, returning the paths where it does and doesn't
+func (r *symbolicRun) branch(s *symbolicPath, p poly, rel, opposite relation) (yes, no *symbolicPath) {
+	can, must := r.possible(p, rel)
+	if must {
+		return s, nil
+	}
+	canNot, mustNot := r.possible(p, opposite)
+	if mustNot || !can {
+		return nil, s
+	}
+	if !canNot {
+		return s, nil
+	}
+	no = s.fork()
+	s.constraints = append(s.constraints, constraint{p, rel})
+	no.constraints = append(no.constraints, constraint{p, opposite})
+	return s, no
+}
+
+// concretize forks s once for every assignment of the symbols in p, substituting
+// the values everywhere.  It is the fallback when a symbolic value is used as a
+// write address, jump target or instruction.
+func (r *symbolicRun) concretize(s *symbolicPath, p poly) ([]*symbolicPath, error) {
+	names := p.symbols()
+	vars := make([]variable, len(names))
+	for i, name := range names {
+		v, ok := r.symbols[name]
+		if !ok {
+			return nil, fmt.Errorf("can't concretize %s at %d", name, s.ip)
+		}
+		vars[i] = v
+	}
+	space := search{vars: vars}
+	if space.count() > r.maxPaths {
+		return nil, fmt.Errorf("concretizing %v at %d needs %d paths", p, s.ip, space.count())
+	}
+
+	paths := make([]*symbolicPath, 0, space.count())
+	for k := 0; k < space.count(); k++ {
+		values := space.assignment(k)
+		vals := make(map[string]int)
+		for i, name := range names {
+			vals[name] = values[i]
+		}
+		f := s.fork()
+		for i := range f.memory {
+			f.memory[i] = f.memory[i].substitute(vals)
+		}
+		for i := range f.outputs {
+			f.outputs[i] = f.outputs[i].substitute(vals)
+		}
+		feasible := true
+		for i, c := range f.constraints {
+			f.constraints[i].p = c.p.substitute(vals)
+			if can, _ := r.possible(f.constraints[i].p, c.rel); !can {
+				feasible = false
+			}
+		}
+		if !feasible {
+			continue
+		}
+		for name, a := range f.atoms {
+			mem := make([]poly, len(a.memory))
+			for i := range mem {
+				mem[i] = a.memory[i].substitute(vals)
+			}
+			f.atoms[name] = atom{addr: a.addr.substitute(vals), memory: mem}
+		}
+		for name, v := range vals {
+			f.fixed[name] = v
+		}
+		paths = append(paths, f)
+	}
+	return paths, nil
+}
+
+// concrete returns the value of p on s, or forks s so that it becomes concrete
+func (r *symbolicRun) concrete(s *symbolicPath, p poly) (int, []*symbolicPath, error) {
+	if v, ok := p.value(); ok {
+		return v, nil, nil
+	}
+	paths, err := r.concretize(s, p)
+	return 0, paths, err
+}
+
+func (r *symbolicRun) address(s *symbolicPath, n int, modes [3]int) (poly, error) {
+	val, err := s.read(s.ip + n)
+	if err != nil {
+		return nil, err
+	}
+	switch modes[n-1] {
+	case positionMode:
+		return val, nil
+	case relativeMode:
+		return val.add(constant(s.relativeBase)), nil
+	}
+	return nil, fmt.Errorf("bad mode %d for parameter %d at %d", modes[n-1], n, s.ip)
+}
+
+func (r *symbolicRun) param(s *symbolicPath, n int, modes [3]int) (poly, error) {
+	if modes[n-1] == immediateMode {
+		return s.read(s.ip + n)
+	}
+	addr, err := r.address(s, n, modes)
+	if err != nil {
+		return nil, err
+	}
+	if a, ok := addr.value(); ok {
+		return s.read(a)
+	}
+	name := fmt.Sprintf("mem@%d", len(s.atoms))
+	s.atoms[name] = atom{addr: addr, memory: append([]poly{}, s.memory...)}
+	return symbol(name), nil
+}
+
+// destination resolves the nth parameter to a concrete write address, or forks s
+func (r *symbolicRun) destination(s *symbolicPath, n int, modes [3]int) (int, []*symbolicPath, error) {
+	addr, err := r.address(s, n, modes)
+	if err != nil {
+		return 0, nil, err
+	}
+	return r.concrete(s, addr)
+}
+
+// step executes one instruction of s.  It returns the paths to continue with,
+// which may be s itself or forks of it, and nothing once s halts.  Forks made
+// to concretize a value restart the instruction, so they are made before any
+// other effect of the instruction.
+func (r *symbolicRun) step(s *symbolicPath) ([]*symbolicPath, error) {
+	instruction, err := s.read(s.ip)
+	if err != nil {
+		return nil, err
+	}
+	code, forks, err := r.concrete(s, instruction)
+	if forks != nil || err != nil {
+		return forks, err
+	}
+	opcode, modes := decode(code)
+
+	switch opcode {
+	case 1, 2, 7, 8: // ADD, MUL, LT, EQ
+		dest, forks, err := r.destination(s, 3, modes)
+		if forks != nil || err != nil {
+			return forks, err
+		}
+		p1, err := r.param(s, 1, modes)
+		if err != nil {
+			return nil, err
+		}
+		p2, err := r.param(s, 2, modes)
+		if err != nil {
+			return nil, err
+		}
+		switch opcode {
+		case 1:
+			err = s.write(dest, p1.add(p2))
+		case 2:
+			err = s.write(dest, p1.mul(p2))
+		default:
+			rel, opposite := lessZero, notLessZero
+			if opcode == 8 {
+				rel, opposite = equalZero, notZero
+			}
+			yes, no := r.branch(s, p1.sub(p2), rel, opposite)
+			paths := make([]*symbolicPath, 0, 2)
+			for _, b := range []struct {
+				path *symbolicPath
+				val  int
+			}{{yes, 1}, {no, 0}} {
+				if b.path == nil {
+					continue
+				}
+				if err := b.path.write(dest, constant(b.val)); err != nil {
+					return nil, err
+				}
+				b.path.ip += 4
+				paths = append(paths, b.path)
+			}
+			return paths, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		s.ip += 4
+	case 3: // INP
+		dest, forks, err := r.destination(s, 1, modes)
+		if forks != nil || err != nil {
+			return forks, err
+		}
+		if len(s.inputs) == 0 {
+			return nil, fmt.Errorf("%w at %d", errInputExhausted, s.ip)
+		}
+		if err := s.write(dest, constant(s.inputs[0])); err != nil {
+			return nil, err
+		}
+		s.inputs = s.inputs[1:]
+		s.ip += 2
+	case 4: // OUTP
+		p, err := r.param(s, 1, modes)
+		if err != nil {
+			return nil, err
+		}
+		s.outputs = append(s.outputs, p)
+		s.ip += 2
+	case 5, 6: // JNZ, JZ
+		cond, err := r.param(s, 1, modes)
+		if err != nil {
+			return nil, err
+		}
+		target, err := r.param(s, 2, modes)
+		if err != nil {
+			return nil, err
+		}
+		rel, opposite := notZero, equalZero
+		if opcode == 6 {
+			rel, opposite = equalZero, notZero
+		}
+		if can, _ := r.possible(cond, rel); can {
+			if _, ok := target.value(); !ok {
+				return r.concretize(s, target)
+			}
+		}
+		jump, fall := r.branch(s, cond, rel, opposite)
+		paths := make([]*symbolicPath, 0, 2)
+		if fall != nil {
+			fall.ip += 3
+			paths = append(paths, fall)
+		}
+		if jump != nil {
+			jump.ip, _ = target.value()
+			paths = append(paths, jump)
+		}
+		return paths, nil
+	case 9: // ARB
+		p, err := r.param(s, 1, modes)
+		if err != nil {
+			return nil, err
+		}
+		v, forks, err := r.concrete(s, p)
+		if forks != nil || err != nil {
+			return forks, err
+		}
+		s.relativeBase += v
+		s.ip += 2
+	case 99: // EXT
+		r.halted = append(r.halted, s)
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("error token at %d: %d", s.ip, code)
+	}
+	return []*symbolicPath{s}, nil
+}
+
+// explore runs every path to completion, depth first
+func (r *symbolicRun) explore() error {
+	start := &symbolicPath{
+		memory: make([]poly, len(r.program)),
+		inputs: r.inputs,
+		fixed:  make(map[string]int),
+		atoms:  make(map[string]atom),
+	}
+	for i, v := range r.program {
+		start.memory[i] = constant(v)
+	}
+	for name, v := range r.symbols {
+		if err := start.write(v.addr, symbol(name)); err != nil {
+			return err
+		}
+	}
+
+	paths := 1
+	stack := []*symbolicPath{start}
+	for len(stack) > 0 {
+		s := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if s.steps++; r.maxSteps > 0 && s.steps > r.maxSteps {
+			s.err = fmt.Errorf("%w: more than %d steps at %d", errBudgetExceeded, r.maxSteps, s.ip)
+			r.failed = append(r.failed, s)
+			continue
+		}
+		next, err := r.step(s)
+		if err != nil {
+			s.err = err
+			r.failed = append(r.failed, s)
+			continue
+		}
+		if len(next) > 1 {
+			paths += len(next) - 1
+			if paths > r.maxPaths {
+				return fmt.Errorf("more than %d paths", r.maxPaths)
+			}
+		}
+		stack = append(stack, next...)
+	}
+	return nil
+}
+
+// solveTarget finds the symbol values on path s that make p equal target.  A
+// linear p is solved directly for its widest ranging symbol while the other
+// symbols of p and the path constraints are enumerated.
+func (r *symbolicRun) solveTarget(s *symbolicPath, p poly, target int) ([]map[string]int, error) {
+	names := s.dependencies(p)
+	for _, c := range s.constraints {
+		names = append(names, s.dependencies(c.p)...)
+	}
+	enumerate := make([]string, 0)
+	solveFor := ""
+	seen := make(map[string]bool)
+	for _, name := range names {
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		v, ok := r.symbols[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown symbol %s", name)
+		}
+		if p.linear() && p[name] != 0 && (solveFor == "" || v.size() > r.symbols[solveFor].size()) {
+			if solveFor != "" {
+				enumerate = append(enumerate, solveFor)
+			}
+			solveFor = name
+			continue
+		}
+		enumerate = append(enumerate, name)
+	}
+	// atoms hide symbols, so they can only be checked by enumeration
+	for _, name := range p.symbols() {
+		if _, ok := s.atoms[name]; ok && solveFor != "" {
+			enumerate = append(enumerate, solveFor)
+			solveFor = ""
+		}
+	}
+	sort.Strings(enumerate)
+
+	vars := make([]variable, len(enumerate))
+	for i, name := range enumerate {
+		vars[i] = r.symbols[name]
+	}
+	space := search{vars: vars}
+	solutions := make([]map[string]int, 0)
+	for k := 0; k < space.count(); k++ {
+		values := space.assignment(k)
+		vals := make(map[string]int)
+		for i, name := range enumerate {
+			vals[name] = values[i]
+		}
+		if solveFor != "" {
+			rest := p.substitute(vals)
+			coef := rest[solveFor]
+			remainder := target - rest[""]
+			if remainder%coef != 0 {
+				continue
+			}
+			v := remainder / coef
+			if v < r.symbols[solveFor].min || v > r.symbols[solveFor].max {
+				continue
+			}
+			vals[solveFor] = v
+		}
+		if val, ok := s.resolve(p, vals).value(); !ok || val != target {
+			continue
+		}
+		if s.satisfied(vals) {
+			for name, v := range s.fixed {
+				vals[name] = v
+			}
+			solutions = append(solutions, vals)
+		}
+	}
+	return solutions, nil
+}
+
+// dependencies lists the symbols p depends on, looking through atoms to the
+// symbols in their addresses and in the memory they were read from
+func (s *symbolicPath) dependencies(p poly) []string {
+	names := make([]string, 0)
+	for _, name := range p.symbols() {
+		a, ok := s.atoms[name]
+		if !ok {
+			names = append(names, name)
+			continue
+		}
+		names = append(names, s.dependencies(a.addr)...)
+		for _, cell := range a.memory {
+			names = append(names, s.dependencies(cell)...)
+		}
+	}
+	return names
+}
+
+// satisfied checks the path constraints under a full assignment
+func (s *symbolicPath) satisfied(vals map[string]int) bool {
+	for _, c := range s.constraints {
+		v, ok := s.resolve(c.p, vals).value()
+		if !ok || !c.rel.holds(v) {
+			return false
+		}
+	}
+	return true
+}
+
+// errNoPaths is returned when every path failed
+var errNoPaths = errors.New("no path halted")
+
+// solve collects the solutions for memory[addr] == target across every halted path
+func (r *symbolicRun) solve(addr int, target int) ([]map[string]int, error) {
+	if len(r.halted) == 0 {
+		return nil, errNoPaths
+	}
+	solutions := make([]map[string]int, 0)
+	for _, s := range r.halted {
+		p, err := s.read(addr)
+		if err != nil {
+			return nil, err
+		}
+		found, err := r.solveTarget(s, p, target)
+		if err != nil {
+			return nil, err
+		}
+		solutions = append(solutions, found...)
+	}
+	return solutions, nil
+}
+
+// formatAssignment lists symbol values sorted by name
+func formatAssignment(vals map[string]int) string {
+	names := make([]string, 0, len(vals))
+	for name := range vals {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	parts := make([]string, len(names))
+	for i, name := range names {
+		parts[i] = fmt.Sprintf("%s=%d", name, vals[name])
+	}
+	return strings.Join(parts, " ")
+}
+
+// symbolList collects repeated -sym flags of the form name=addr:min:max
+type symbolList map[string]variable
+
+func (l symbolList) String() string {
+	names := make([]string, 0, len(l))
+	for name := range l {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	parts := make([]string, len(names))
+	for i, name := range names {
+		v := l[name]
+		parts[i] = fmt.Sprintf("%s=%d:%d:%d", name, v.addr, v.min, v.max)
+	}
+	return strings.Join(parts, ",")
+}
+
+func (l symbolList) Set(text string) error {
+	name, spec := "", text
+	if i := strings.Index(text, "="); i >= 0 {
+		name, spec = text[:i], text[i+1:]
+	}
+	v, err := parseVariable(spec)
+	if err != nil {
+		return err
+	}
+	if v.min > v.max {
+		return fmt.Errorf("empty range %d:%d for address %d", v.min, v.max, v.addr)
+	}
+	if name == "" {
+		name = fmt.Sprintf("m%d", v.addr)
+	}
+	if strings.ContainsAny(name, "*@") {
+		return fmt.Errorf("symbol name %q can't contain '*' or '@'", name)
+	}
+	l[name] = v
+	return nil
+}